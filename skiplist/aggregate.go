@@ -0,0 +1,221 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// A Monoid describes how to aggregate values: Combine must be
+// associative, and Identity must be its neutral element. For example,
+// integer addition with 0, or max with the smallest possible value.
+// Combine doesn't need to be commutative; values are always combined
+// in key order.
+type Monoid struct {
+	Identity interface{}
+	Combine  func(l, r interface{}) interface{}
+}
+
+// An AggregateSkipList is a map that can compute the aggregate of the
+// values in any range of keys in O(log n) time. Every forward link of
+// the underlying skip list stores the aggregate of the values it skips
+// over, and Set and Delete keep these partial aggregates up to date in
+// O(log n) time.
+type AggregateSkipList struct {
+	list   *SkipList
+	monoid Monoid
+}
+
+// aggregateEntry is the value stored in the nodes of an
+// AggregateSkipList.
+type aggregateEntry struct {
+	value interface{}
+	// links[i] is the aggregate of the values from this node
+	// (inclusive) to forward[i] (exclusive).
+	links []interface{}
+}
+
+// NewAggregateSkipList returns a new AggregateSkipList that will use
+// lessThan as the comparison function and aggregate values with
+// monoid.
+func NewAggregateSkipList(lessThan func(l, r interface{}) bool, monoid Monoid) *AggregateSkipList {
+	a := &AggregateSkipList{
+		list:   NewCustomMap(lessThan),
+		monoid: monoid,
+	}
+	a.list.header.value = &aggregateEntry{}
+	a.recompute(a.list.header, 0)
+	return a
+}
+
+// Len returns the length of a.
+func (a *AggregateSkipList) Len() int {
+	return a.list.Len()
+}
+
+func (a *AggregateSkipList) entry(n *node) *aggregateEntry {
+	return n.value.(*aggregateEntry)
+}
+
+// unwrapAggregate returns the value held in an aggregateEntry.
+func unwrapAggregate(value interface{}) interface{} {
+	return value.(*aggregateEntry).value
+}
+
+// recompute updates the aggregate stored in the forward link of n at
+// the given level. The links at the level below must be up to date.
+func (a *AggregateSkipList) recompute(n *node, level int) {
+	e := a.entry(n)
+	if len(e.links) != len(n.forward) {
+		links := make([]interface{}, len(n.forward))
+		copy(links, e.links)
+		e.links = links
+	}
+
+	if level == 0 {
+		if n == a.list.header {
+			e.links[0] = a.monoid.Identity
+		} else {
+			e.links[0] = e.value
+		}
+		return
+	}
+
+	aggregate := e.links[level-1]
+	for current := n.forward[level-1]; current != n.forward[level]; current = current.forward[level-1] {
+		aggregate = a.monoid.Combine(aggregate, a.entry(current).links[level-1])
+	}
+	e.links[level] = aggregate
+}
+
+// fix recomputes the aggregates affected by a modification. update
+// must be the update vector of the modified key, and changed the node
+// that was inserted or whose value was set, if any.
+func (a *AggregateSkipList) fix(update []*node, changed *node) {
+	s := a.list
+	for level := 0; level <= s.level(); level++ {
+		if changed != nil && level < len(changed.forward) {
+			a.recompute(changed, level)
+		}
+		previous := s.header
+		if level < len(update) {
+			previous = update[level]
+		}
+		a.recompute(previous, level)
+	}
+}
+
+// Get returns the value associated with key from a (nil if the key is
+// not present in a). The second return value is true when the key is
+// present.
+func (a *AggregateSkipList) Get(key interface{}) (value interface{}, ok bool) {
+	if value, ok = a.list.Get(key); ok {
+		return unwrapAggregate(value), true
+	}
+	return nil, false
+}
+
+// Set sets the value associated with key in a.
+func (a *AggregateSkipList) Set(key, value interface{}) {
+	if key == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	s := a.list
+	update := make([]*node, s.level()+1, s.effectiveMaxLevel()+1)
+	candidate := s.search(update, key)
+
+	if candidate != nil && s.keysEqual(candidate.key, key) {
+		a.entry(candidate).value = value
+	} else {
+		candidate = s.insert(update, key, &aggregateEntry{value: value})
+	}
+	s.setFinger(update)
+	a.fix(update, candidate)
+}
+
+// Delete removes the node with the given key. It returns the old value
+// and whether the node was present.
+func (a *AggregateSkipList) Delete(key interface{}) (value interface{}, ok bool) {
+	if key == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	s := a.list
+	update := make([]*node, s.level()+1)
+	candidate := s.search(update, key)
+	if candidate == nil || !s.keysEqual(candidate.key, key) {
+		return nil, false
+	}
+
+	value = a.entry(candidate).value
+	s.remove(update, candidate)
+	s.setFinger(update)
+	a.fix(update, nil)
+	return value, true
+}
+
+// Aggregate returns the aggregate of the values of all the elements of
+// a whose keys are greater or equal than from, but less than to. A nil
+// from stands for the first key, and a nil to for the end of a. It
+// takes O(log n) time.
+func (a *AggregateSkipList) Aggregate(from, to interface{}) interface{} {
+	s := a.list
+	current := s.header.next()
+	if from != nil {
+		current = s.search(nil, from)
+	}
+
+	result := a.monoid.Identity
+	for current != nil && s.before(current.key, to) {
+		// Follow the highest link that doesn't skip over to.
+		level := len(current.forward) - 1
+		for ; level > 0; level-- {
+			next := current.forward[level]
+			if to == nil || next != nil && !s.lessThan(to, next.key) {
+				break
+			}
+		}
+		result = a.monoid.Combine(result, a.entry(current).links[level])
+		current = current.forward[level]
+	}
+	return result
+}
+
+// Iterator returns an Iterator that will go through all the elements
+// of a.
+func (a *AggregateSkipList) Iterator() Iterator {
+	return unwrapped(a.list.Iterator(), unwrapAggregate)
+}
+
+// Seek returns a bidirectional iterator starting with the first
+// element whose key is greater or equal to key; otherwise, a nil
+// iterator is returned.
+func (a *AggregateSkipList) Seek(key interface{}) Iterator {
+	return unwrapped(a.list.Seek(key), unwrapAggregate)
+}
+
+// Range returns an iterator that will go through all the elements of a
+// that are greater or equal than from, but less than to.
+func (a *AggregateSkipList) Range(from, to interface{}) Iterator {
+	return unwrapped(a.list.Range(from, to), unwrapAggregate)
+}
+
+// unwrapIter is an Iterator over a SkipList whose values are wrapped,
+// for example in an aggregateEntry. It returns the unwrapped values.
+type unwrapIter struct {
+	Iterator
+	unwrap func(value interface{}) interface{}
+}
+
+// unwrapped returns an Iterator returning the values of i unwrapped
+// with unwrap, or nil if i is nil.
+func unwrapped(i Iterator, unwrap func(value interface{}) interface{}) Iterator {
+	if i == nil {
+		return nil
+	}
+	return &unwrapIter{i, unwrap}
+}
+
+func (i *unwrapIter) Value() interface{} {
+	value := i.Iterator.Value()
+	if value == nil {
+		return nil
+	}
+	return i.unwrap(value)
+}
@@ -0,0 +1,114 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var sumMonoid = Monoid{
+	Identity: 0,
+	Combine: func(l, r interface{}) interface{} {
+		return l.(int) + r.(int)
+	},
+}
+
+// concatMonoid is not commutative, so it checks that values are
+// combined in key order.
+var concatMonoid = Monoid{
+	Identity: "",
+	Combine: func(l, r interface{}) interface{} {
+		return l.(string) + r.(string)
+	},
+}
+
+func TestAggregate(t *testing.T) {
+	a := NewAggregateSkipList(intLessThan, concatMonoid)
+	if result := a.Aggregate(nil, nil); result != "" {
+		t.Errorf("The aggregate of an empty list should be empty, not %q.", result)
+	}
+
+	expected := make(map[int]string)
+	for i := 0; i < 2000; i++ {
+		key := rand.Intn(200)
+		if rand.Intn(3) == 0 {
+			value, ok := a.Delete(key)
+			if ok != (expected[key] != "") || ok && value != expected[key] {
+				t.Fatalf("Delete(%v) returned %v, %v instead of %q.", key, value, ok, expected[key])
+			}
+			delete(expected, key)
+		} else {
+			value := fmt.Sprintf("%d;", rand.Intn(10))
+			a.Set(key, value)
+			expected[key] = value
+		}
+		if err := a.list.Verify(); err != nil {
+			t.Fatalf("Verify failed: %v.", err)
+		}
+
+		from, to := rand.Intn(220)-10, rand.Intn(220)-10
+		want := ""
+		for k := from; k < to; k++ {
+			want += expected[k]
+		}
+		if got := a.Aggregate(from, to); got != want {
+			t.Fatalf("Aggregate(%v, %v) should be %q, not %q.", from, to, want, got)
+		}
+	}
+
+	want := ""
+	for k := 0; k < 200; k++ {
+		want += expected[k]
+	}
+	if got := a.Aggregate(nil, nil); got != want {
+		t.Errorf("Aggregate(nil, nil) should be %q, not %q.", want, got)
+	}
+	if a.Len() != len(expected) {
+		t.Errorf("Len should be %v, not %v.", len(expected), a.Len())
+	}
+}
+
+func TestAggregateIterator(t *testing.T) {
+	a := NewAggregateSkipList(intLessThan, sumMonoid)
+	for i := 0; i < 10; i++ {
+		a.Set(i, i*i)
+	}
+	if value, ok := a.Get(3); !ok || value != 9 {
+		t.Errorf("Get(3) should return 9, true, not %v, %v.", value, ok)
+	}
+	if sum := a.Aggregate(2, 5); sum != 4+9+16 {
+		t.Errorf("Aggregate(2, 5) should be 29, not %v.", sum)
+	}
+
+	count := 0
+	for i := a.Range(2, 5); i.Next(); count++ {
+		if i.Value() != i.Key().(int)*i.Key().(int) {
+			t.Errorf("Value of %v should be its square, not %v.", i.Key(), i.Value())
+		}
+	}
+	if count != 3 {
+		t.Errorf("Range(2, 5) should have 3 elements, not %v.", count)
+	}
+	if i := a.Seek(100); i != nil {
+		t.Errorf("Seek past the last key should return nil.")
+	}
+	if i := a.Seek(4); i == nil || i.Value() != 16 {
+		t.Errorf("Seek(4) should point to 16.")
+	}
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	b.StopTimer()
+	a := NewAggregateSkipList(intLessThan, sumMonoid)
+	for i := 0; i < 65536; i++ {
+		a.Set(i, i)
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		from := rand.Intn(65536)
+		a.Aggregate(from, from+rand.Intn(65536))
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// branching is the number of nodes at level i for every node at level
+// i+1 in a perfectly balanced skip list.
+const branching = int(1 / p)
+
+// rebuild replaces all the towers of s. The i-th node (counting from
+// 1) gets a tower of level levelOf(i). It takes O(n) time.
+func (s *SkipList) rebuild(levelOf func(i int) int) {
+	s.finger = nil
+	s.header.forward = s.header.forward[:1]
+	last := []*node{s.header}
+
+	i := 0
+	for current := s.header.next(); current != nil; current = current.next() {
+		i++
+		level := levelOf(i)
+		current.forward = current.forward[:1]
+		for l := 1; l <= level; l++ {
+			if l == len(last) {
+				last = append(last, s.header)
+				s.header.forward = append(s.header.forward, nil)
+			}
+			current.forward = append(current.forward, nil)
+			last[l].forward[l] = current
+			last[l] = current
+		}
+	}
+}
+
+// Rebalance gives new random heights to all the towers of s. This is
+// useful after loading many elements while MaxLevel was too low, as
+// otherwise the list would remain degenerated (increasing MaxLevel
+// affects only the towers of elements inserted later). It takes O(n)
+// time.
+func (s *SkipList) Rebalance() {
+	if s.deterministic {
+		return
+	}
+	maxLevel := s.maxLevel()
+	s.rebuild(func(int) int {
+		return randomLevelUpTo(maxLevel)
+	})
+}
+
+// Compact rebuilds the towers of s so that they form a perfectly
+// balanced skip list: every 1/p-th node of each level is promoted to
+// the next level (up to the maximum level). This guarantees that
+// searches take O(log n) time in the worst case, until s is modified
+// (new elements get random levels, as usual). It is useful after
+// loading a list that will be mostly read. Compact takes O(n) time.
+func (s *SkipList) Compact() {
+	if s.deterministic {
+		return
+	}
+	maxLevel := s.maxLevel()
+	s.rebuild(func(i int) (level int) {
+		for ; level < maxLevel && i%branching == 0; level++ {
+			i /= branching
+		}
+		return level
+	})
+}
+
+// Rebalance gives new random heights to all the towers of the
+// underlying skip list. See SkipList.Rebalance.
+func (s *Set) Rebalance() {
+	s.skiplist.Rebalance()
+}
+
+// Compact rebuilds the towers of the underlying skip list into a
+// perfectly balanced layout. See SkipList.Compact.
+func (s *Set) Compact() {
+	s.skiplist.Compact()
+}
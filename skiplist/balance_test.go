@@ -0,0 +1,149 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAutoMaxLevel(t *testing.T) {
+	s := NewIntMap()
+	s.AutoMaxLevel = true
+
+	for i := 0; i < 4; i++ {
+		s.Set(i, i)
+	}
+	if l := s.maxLevel(); l != 2 {
+		t.Errorf("With 4 elements the maximum level should be 2, not %v.", l)
+	}
+
+	for i := 4; i < 65536; i++ {
+		s.Set(i, i)
+	}
+	if l := s.level(); l > 8 {
+		t.Errorf("With 65536 elements the level should be at most 8, not %v.", l)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+
+	for i := 16; i < 65536; i++ {
+		s.Delete(i)
+	}
+	if l := s.level(); l > 3 {
+		t.Errorf("With 16 elements the level should be at most 3, not %v.", l)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+	for i := 0; i < 16; i++ {
+		s.check(t, i, i)
+	}
+
+	s.MaxLevel = 1
+	for i := 16; i < 1000; i++ {
+		s.Set(i, i)
+	}
+	if l := s.level(); l > 3 {
+		t.Errorf("MaxLevel should still limit the level, got %v.", l)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	s := NewIntMap()
+	s.MaxLevel = 0
+	for i := 0; i < 10000; i++ {
+		s.Set(i, i)
+	}
+	if l := s.level(); l != 0 {
+		t.Fatalf("With MaxLevel 0 the level should be 0, not %v.", l)
+	}
+
+	s.MaxLevel = DefaultMaxLevel
+	s.Rebalance()
+	if l := s.level(); l < 4 {
+		t.Errorf("After Rebalance the level should be around 6, not %v.", l)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+	for i := 0; i < 10000; i += 7 {
+		s.check(t, i, i)
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := rand.Intn(20000)
+		s.Set(key, key)
+		s.Delete(rand.Intn(20000))
+	}
+	if err := s.Verify(); err != nil {
+		t.Errorf("Verify failed after modifying a rebalanced list: %v.", err)
+	}
+
+	set := NewIntSet()
+	set.Rebalance()
+	if err := set.Verify(); err != nil {
+		t.Errorf("Verify failed after rebalancing an empty set: %v.", err)
+	}
+}
+
+// searchCost returns the number of nodes visited when looking for key.
+func (s *SkipList) searchCost(key interface{}) (cost int) {
+	current := s.header
+	for i := s.level(); i >= 0; i-- {
+		for current.forward[i] != nil && s.lessThan(current.forward[i].key, key) {
+			current = current.forward[i]
+			cost++
+		}
+		cost++
+	}
+	return cost
+}
+
+func TestCompact(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 5000; i++ {
+		key := rand.Int()
+		s.Set(key, key)
+	}
+	s.Compact()
+
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+
+	stats := s.Stats()
+	expected := s.Len()
+	for level, nodes := range stats.NodesPerLevel {
+		if nodes != expected {
+			t.Errorf("Level %d should have %d nodes, not %d.", level, expected, nodes)
+		}
+		expected /= branching
+	}
+	if expected != 0 {
+		t.Errorf("The list has too few levels: %v.", stats.NodesPerLevel)
+	}
+
+	// Every level takes at most branching steps.
+	maxCost := (s.level() + 1) * branching
+	for i := s.Iterator(); i.Next(); {
+		if cost := s.searchCost(i.Key()); cost > maxCost {
+			t.Errorf("Looking for %v took %d steps (more than %d).", i.Key(), cost, maxCost)
+		}
+		s.check(t, i.Key().(int), i.Value().(int))
+	}
+
+	s.MaxLevel = 2
+	s.Compact()
+	if l := s.level(); l != 2 {
+		t.Errorf("Compact should respect MaxLevel, got level %v.", l)
+	}
+
+	s.Set(-1, -1)
+	s.Delete(s.header.next().next().key)
+	if err := s.Verify(); err != nil {
+		t.Errorf("Verify failed after modifying a compacted list: %v.", err)
+	}
+}
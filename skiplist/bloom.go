@@ -0,0 +1,211 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// A skip list can keep a Bloom filter of its keys, so that Get can
+// answer most lookups for absent keys without searching the list. The
+// filter is updated when keys are set. Removed keys stay in the filter
+// until it is rebuilt, which happens when they make up half of its
+// keys, or when it has as many keys as it was sized for.
+
+// minBloomCapacity is the smallest number of keys a filter is sized
+// for.
+const minBloomCapacity = 64
+
+// BloomStats describes the Bloom filter of a skip list.
+type BloomStats struct {
+	// Lookups is the number of calls to Get that consulted the
+	// filter.
+	Lookups int64
+	// Negatives is the number of lookups answered by the filter
+	// alone, because the key was certainly absent.
+	Negatives int64
+	// FalsePositives is the number of lookups for which the
+	// filter couldn't rule out a key that was absent.
+	FalsePositives int64
+	// Rebuilds is the number of times the filter was rebuilt.
+	Rebuilds int64
+	// Bits is the size of the filter, and Hashes the number of
+	// bits set for every key.
+	Bits   int
+	Hashes int
+	// Keys is the number of keys added to the filter since it was
+	// last built, and Stale the number of them that were removed
+	// from the list since.
+	Keys  int
+	Stale int
+}
+
+// FalsePositiveRate returns the fraction of the lookups for absent
+// keys that the filter failed to answer.
+func (b BloomStats) FalsePositiveRate() float64 {
+	if b.Negatives+b.FalsePositives == 0 {
+		return 0
+	}
+	return float64(b.FalsePositives) / float64(b.Negatives+b.FalsePositives)
+}
+
+type bloomFilter struct {
+	// The counters are updated by Get, so they are accessed
+	// atomically to allow concurrent readers. They come first to
+	// be 64-bit aligned.
+	lookups, negatives, falsePositives int64
+	rebuilds                           int64
+
+	hash       func(key interface{}) uint64
+	bitsPerKey int
+	hashes     int
+	bits       []uint64
+	capacity   int
+	keys       int
+	stale      int
+}
+
+// probe calls fn with the word and mask of every bit of the filter
+// for a key with hash h, using double hashing.
+func (f *bloomFilter) probe(h uint64, fn func(word int, mask uint64) bool) bool {
+	n := uint64(len(f.bits)) * 64
+	delta := h>>32 | h<<32 | 1
+	for i := 0; i < f.hashes; i++ {
+		bit := h % n
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
+
+func (f *bloomFilter) add(key interface{}) {
+	f.probe(f.hash(key), func(word int, mask uint64) bool {
+		f.bits[word] |= mask
+		return true
+	})
+	f.keys++
+}
+
+// mayContain returns false if key is certainly not in the filter.
+func (f *bloomFilter) mayContain(key interface{}) bool {
+	atomic.AddInt64(&f.lookups, 1)
+	ok := f.probe(f.hash(key), func(word int, mask uint64) bool {
+		return f.bits[word]&mask != 0
+	})
+	if !ok {
+		atomic.AddInt64(&f.negatives, 1)
+	}
+	return ok
+}
+
+// falsePositive records a lookup for an absent key that mayContain
+// didn't rule out.
+func (f *bloomFilter) falsePositive() {
+	atomic.AddInt64(&f.falsePositives, 1)
+}
+
+// rebuild sizes the filter for twice the number of elements of s, and
+// adds all their keys.
+func (f *bloomFilter) rebuild(s *SkipList) {
+	f.capacity = 2 * s.Len()
+	if f.capacity < minBloomCapacity {
+		f.capacity = minBloomCapacity
+	}
+	f.bits = make([]uint64, (f.capacity*f.bitsPerKey+63)/64)
+	f.keys, f.stale = 0, 0
+	for current := s.visible(s.header.next()); current != nil; current = s.visible(current.next()) {
+		f.add(current.key)
+	}
+	atomic.AddInt64(&f.rebuilds, 1)
+}
+
+// maybeRebuild rebuilds the filter if it is full or if too many of
+// its keys were removed.
+func (f *bloomFilter) maybeRebuild(s *SkipList) {
+	if f.keys >= f.capacity || 2*f.stale > f.keys {
+		f.rebuild(s)
+	}
+}
+
+// EnableBloomFilter makes s keep a Bloom filter of its keys, using
+// about bitsPerKey bits for every key (10 bits give a false positive
+// rate of about 1%). hash must return the same value for keys that s
+// considers equal; HashInt, HashString and HashBytes can be used for
+// int, string and []byte keys. The filter makes Get faster for absent
+// keys, at the cost of slightly slower modifications.
+func (s *SkipList) EnableBloomFilter(hash func(key interface{}) uint64, bitsPerKey int) {
+	if bitsPerKey < 1 {
+		panic("goskiplist: a Bloom filter needs at least one bit per key")
+	}
+	hashes := int(math.Floor(float64(bitsPerKey)*math.Ln2 + 0.5))
+	if hashes < 1 {
+		hashes = 1
+	}
+	s.bloom = &bloomFilter{
+		hash:       hash,
+		bitsPerKey: bitsPerKey,
+		hashes:     hashes,
+	}
+	s.bloom.rebuild(s)
+}
+
+// BloomStats returns statistics about the Bloom filter of s, and
+// whether s has one. It may be called concurrently with Get, but not
+// with methods that modify s.
+func (s *SkipList) BloomStats() (stats BloomStats, ok bool) {
+	f := s.bloom
+	if f == nil {
+		return BloomStats{}, false
+	}
+	return BloomStats{
+		Lookups:        atomic.LoadInt64(&f.lookups),
+		Negatives:      atomic.LoadInt64(&f.negatives),
+		FalsePositives: atomic.LoadInt64(&f.falsePositives),
+		Rebuilds:       atomic.LoadInt64(&f.rebuilds),
+		Bits:           len(f.bits) * 64,
+		Hashes:         f.hashes,
+		Keys:           f.keys,
+		Stale:          f.stale,
+	}, true
+}
+
+// HashInt hashes int keys for EnableBloomFilter.
+func HashInt(key interface{}) uint64 {
+	// The finalizer of SplitMix64.
+	h := uint64(key.(int))
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return h ^ h>>31
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// HashString hashes string keys for EnableBloomFilter, using FNV-1a.
+func HashString(key interface{}) uint64 {
+	h := uint64(fnvOffset)
+	s := key.(string)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return h
+}
+
+// HashBytes hashes []byte keys (see NewBytesMap) for
+// EnableBloomFilter, using FNV-1a. It returns the same hash as
+// HashString for the same bytes.
+func HashBytes(key interface{}) uint64 {
+	h := uint64(fnvOffset)
+	for _, c := range key.([]byte) {
+		h ^= uint64(c)
+		h *= fnvPrime
+	}
+	return h
+}
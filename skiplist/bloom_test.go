@@ -0,0 +1,147 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 100; i++ {
+		s.Set(i, i)
+	}
+	s.EnableBloomFilter(HashInt, 10)
+	for i := 100; i < 1000; i++ {
+		s.Set(i, i)
+	}
+	for i := 0; i < 1000; i++ {
+		s.check(t, i, i)
+	}
+	for i := 1000; i < 11000; i++ {
+		if _, ok := s.Get(i); ok {
+			t.Fatalf("%v should be absent.", i)
+		}
+	}
+
+	stats, ok := s.BloomStats()
+	if !ok {
+		t.Fatalf("BloomStats should report the filter.")
+	}
+	if stats.Lookups != 11000 || stats.Negatives+stats.FalsePositives != 10000 {
+		t.Errorf("There should be 11000 lookups and 10000 misses, not %+v.", stats)
+	}
+	if rate := stats.FalsePositiveRate(); rate > 0.05 {
+		t.Errorf("The false positive rate should be about 1%%, not %v.", rate)
+	}
+	if stats.Rebuilds < 2 {
+		t.Errorf("The filter should have been rebuilt as it grew, not %v times.", stats.Rebuilds)
+	}
+
+	rebuilds := stats.Rebuilds
+	for i := 0; i < 900; i++ {
+		s.Delete(i)
+	}
+	s.DeleteRange(900, 950)
+	if stats, _ = s.BloomStats(); stats.Rebuilds == rebuilds {
+		t.Errorf("The filter should have been rebuilt after the deletions.")
+	}
+	if 2*stats.Stale > stats.Keys {
+		t.Errorf("At most half of the keys should be stale, not %v of %v.", stats.Stale, stats.Keys)
+	}
+	for i := 950; i < 1000; i++ {
+		s.check(t, i, i)
+	}
+
+	if _, ok := NewIntMap().BloomStats(); ok {
+		t.Errorf("A list without a filter should not report stats.")
+	}
+}
+
+func TestBloomFilterTombstones(t *testing.T) {
+	for _, s := range []*SkipList{NewStringMap(), NewDeterministicMap(func(l, r interface{}) bool {
+		return l.(string) < r.(string)
+	})} {
+		s.EnableTombstones()
+		s.EnableBloomFilter(HashString, 8)
+		for i := 0; i < 500; i++ {
+			s.Set(fmt.Sprint(i), i)
+		}
+		for i := 0; i < 500; i += 2 {
+			s.Delete(fmt.Sprint(i))
+		}
+		for i := 0; i < 500; i += 4 {
+			s.Set(fmt.Sprint(i), -i)
+		}
+		for i := 0; i < 500; i++ {
+			value, ok := s.Get(fmt.Sprint(i))
+			switch {
+			case i%4 == 0:
+				if !ok || value != -i {
+					t.Errorf("%v should be %v, not %v.", i, -i, value)
+				}
+			case i%2 == 0:
+				if ok {
+					t.Errorf("%v should be deleted.", i)
+				}
+			default:
+				if !ok || value != i {
+					t.Errorf("%v should be %v, not %v.", i, i, value)
+				}
+			}
+		}
+		if err := s.Verify(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBloomFilterBytes(t *testing.T) {
+	if HashBytes([]byte("abc")) != HashString("abc") {
+		t.Errorf("HashBytes and HashString should agree on the same bytes.")
+	}
+
+	s := NewBytesMap()
+	s.EnableBloomFilter(HashBytes, 10)
+	for i := 0; i < 1000; i++ {
+		s.Set([]byte(fmt.Sprint(i)), i)
+	}
+	for i := 0; i < 1000; i += 2 {
+		s.Delete([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < 2000; i++ {
+		value, ok := s.Get([]byte(fmt.Sprint(i)))
+		if present := i < 1000 && i%2 == 1; ok != present || ok && value != i {
+			t.Errorf("Get(%v) should return %v, not %v, %v.", i, present, value, ok)
+		}
+	}
+	// The deleted keys are still in the filter, but it should
+	// answer most lookups for the keys that were never set.
+	if stats, _ := s.BloomStats(); stats.Negatives < 900 {
+		t.Errorf("The filter should answer most of the 1000 lookups for keys that were never set, not %+v.", stats)
+	}
+}
+
+func benchmarkGetMiss(b *testing.B, filter bool) {
+	s := NewIntMap()
+	if filter {
+		s.EnableBloomFilter(HashInt, 10)
+	}
+	for i := 0; i < 100000; i++ {
+		s.Set(2*i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Get(2*(i%100000) + 1)
+	}
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+	benchmarkGetMiss(b, false)
+}
+
+func BenchmarkGetMissBloomFilter(b *testing.B) {
+	benchmarkGetMiss(b, true)
+}
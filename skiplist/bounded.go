@@ -0,0 +1,170 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"container/list"
+)
+
+// An EvictionPolicy decides which entry a BoundedMap drops when it
+// grows over its capacity.
+type EvictionPolicy int
+
+const (
+	// EvictSmallest drops the entry with the smallest key, so that
+	// the map keeps the largest keys.
+	EvictSmallest EvictionPolicy = iota
+	// EvictLargest drops the entry with the largest key, so that the
+	// map keeps the smallest keys.
+	EvictLargest
+	// EvictOldest drops the entry that was inserted first. Setting
+	// the value of a key that is already present doesn't make it
+	// newer.
+	EvictOldest
+)
+
+// A BoundedMap is a map holding at most a fixed number of entries. When
+// an insertion makes it exceed its capacity, it evicts an entry
+// according to its policy. Evicting the smallest or the largest key
+// takes O(1) time to find the entry, plus the cost of unlinking it.
+type BoundedMap struct {
+	list     *SkipList
+	capacity int
+	policy   EvictionPolicy
+	// order holds the keys in insertion order, for EvictOldest.
+	order *list.List
+	// OnEvict, if not nil, is called with every evicted entry.
+	OnEvict func(key, value interface{})
+}
+
+type boundedEntry struct {
+	value interface{}
+	// element is the element of the key in order, if any.
+	element *list.Element
+}
+
+// NewBoundedMap returns a new BoundedMap that will use lessThan as the
+// comparison function, hold at most capacity entries, and evict
+// entries according to policy.
+func NewBoundedMap(lessThan func(l, r interface{}) bool, capacity int, policy EvictionPolicy) *BoundedMap {
+	if capacity <= 0 {
+		panic("goskiplist: the capacity must be positive")
+	}
+	m := &BoundedMap{
+		list:     NewCustomMap(lessThan),
+		capacity: capacity,
+		policy:   policy,
+	}
+	if policy == EvictOldest {
+		m.order = list.New()
+	}
+	return m
+}
+
+// Len returns the number of entries in m.
+func (m *BoundedMap) Len() int {
+	return m.list.Len()
+}
+
+// Capacity returns the maximum number of entries in m.
+func (m *BoundedMap) Capacity() int {
+	return m.capacity
+}
+
+// unwrapBounded returns the value held in a boundedEntry.
+func unwrapBounded(value interface{}) interface{} {
+	return value.(*boundedEntry).value
+}
+
+// Get returns the value associated with key from m (nil if the key is
+// not present in m). The second return value is true when the key is
+// present.
+func (m *BoundedMap) Get(key interface{}) (value interface{}, ok bool) {
+	if value, ok = m.list.Get(key); ok {
+		return unwrapBounded(value), true
+	}
+	return nil, false
+}
+
+// Set sets the value associated with key in m. If key is new and m is
+// full, an entry is evicted (possibly the new one, if its key is the
+// one the policy drops).
+func (m *BoundedMap) Set(key, value interface{}) {
+	if key == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	e := &boundedEntry{value: value}
+	old, existed := m.list.set(key, e)
+	if existed {
+		e.element = old.(*boundedEntry).element
+		return
+	}
+	if m.order != nil {
+		e.element = m.order.PushBack(key)
+	}
+	if m.list.Len() > m.capacity {
+		m.evict()
+	}
+}
+
+// Delete removes the entry with the given key. It returns the old
+// value and whether the entry was present.
+func (m *BoundedMap) Delete(key interface{}) (value interface{}, ok bool) {
+	if value, ok = m.list.Delete(key); !ok {
+		return nil, false
+	}
+	e := value.(*boundedEntry)
+	if e.element != nil {
+		m.order.Remove(e.element)
+	}
+	return e.value, true
+}
+
+// evict removes one entry according to the policy of m.
+func (m *BoundedMap) evict() {
+	var key, value interface{}
+	switch m.policy {
+	case EvictSmallest:
+		key, value = m.list.removeFirst()
+	case EvictLargest:
+		key, value = m.list.removeLast()
+	case EvictOldest:
+		key = m.order.Remove(m.order.Front())
+		value, _ = m.list.delete(key)
+	}
+	if m.OnEvict != nil {
+		m.OnEvict(key, unwrapBounded(value))
+	}
+}
+
+// Iterator returns an Iterator that will go through all the entries of
+// m.
+func (m *BoundedMap) Iterator() Iterator {
+	return unwrapped(m.list.Iterator(), unwrapBounded)
+}
+
+// removeFirst removes the first node of s, which must not be empty, and
+// returns its key and value. The header precedes it at every level, so
+// no search is needed.
+func (s *SkipList) removeFirst() (key, value interface{}) {
+	first := s.header.next()
+	update := make([]*node, s.level()+1)
+	for i := range update {
+		update[i] = s.header
+	}
+	s.remove(update, first)
+	s.setFinger(update)
+	return first.key, first.value
+}
+
+// removeLast removes the last node of s, which must not be empty, and
+// returns its key and value.
+func (s *SkipList) removeLast() (key, value interface{}) {
+	last := s.footer
+	update := make([]*node, s.level()+1)
+	s.getPath(s.header, update, last.key)
+	s.remove(update, last)
+	s.setFinger(update)
+	return last.key, last.value
+}
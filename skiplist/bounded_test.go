@@ -0,0 +1,68 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func (m *BoundedMap) keys() (keys []interface{}) {
+	for i := m.Iterator(); i.Next(); {
+		keys = append(keys, i.Key())
+	}
+	return keys
+}
+
+func TestBoundedMap(t *testing.T) {
+	for _, test := range []struct {
+		policy  EvictionPolicy
+		keys    []interface{}
+		evicted []interface{}
+	}{
+		{EvictSmallest, []interface{}{5, 7, 8}, []interface{}{1, 3, 4}},
+		{EvictLargest, []interface{}{1, 3, 4}, []interface{}{8, 7, 5}},
+		{EvictOldest, []interface{}{1, 4, 5}, []interface{}{3, 7, 8}},
+	} {
+		m := NewBoundedMap(intLessThan, 3, test.policy)
+		var evicted []interface{}
+		m.OnEvict = func(key, value interface{}) {
+			if value != key.(int)*10 {
+				t.Errorf("The value of %v should be %v, not %v.", key, key.(int)*10, value)
+			}
+			evicted = append(evicted, key)
+		}
+		for _, key := range []int{3, 7, 8, 1, 7, 4, 5} {
+			m.Set(key, key*10)
+			if err := m.list.Verify(); err != nil {
+				t.Fatalf("Verify failed: %v.", err)
+			}
+		}
+		if keys := m.keys(); !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("Policy %v: the keys should be %v, not %v.", test.policy, test.keys, keys)
+		}
+		if !reflect.DeepEqual(evicted, test.evicted) {
+			t.Errorf("Policy %v: the evicted keys should be %v, not %v.", test.policy, test.evicted, evicted)
+		}
+	}
+}
+
+func TestBoundedMapDelete(t *testing.T) {
+	m := NewBoundedMap(intLessThan, 10, EvictOldest)
+	for i := 0; i < 1000; i++ {
+		key := rand.Intn(30)
+		if rand.Intn(3) == 0 {
+			m.Delete(key)
+		} else {
+			m.Set(key, key)
+		}
+		if m.Len() > 10 || m.Len() != m.order.Len() {
+			t.Fatalf("Len is %v, and the order has %v keys.", m.Len(), m.order.Len())
+		}
+	}
+	if value, ok := m.Get(m.order.Back().Value); !ok || value != m.order.Back().Value {
+		t.Errorf("The newest key should be present, not %v, %v.", value, ok)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"sort"
+)
+
+// A Pair is a key-value pair.
+type Pair struct {
+	Key, Value interface{}
+}
+
+type pairSorter struct {
+	pairs    []Pair
+	lessThan func(l, r interface{}) bool
+}
+
+func (p pairSorter) Len() int           { return len(p.pairs) }
+func (p pairSorter) Less(i, j int) bool { return p.lessThan(p.pairs[i].Key, p.pairs[j].Key) }
+func (p pairSorter) Swap(i, j int)      { p.pairs[i], p.pairs[j] = p.pairs[j], p.pairs[i] }
+
+type keySorter struct {
+	keys     []interface{}
+	lessThan func(l, r interface{}) bool
+}
+
+func (k keySorter) Len() int           { return len(k.keys) }
+func (k keySorter) Less(i, j int) bool { return k.lessThan(k.keys[i], k.keys[j]) }
+func (k keySorter) Swap(i, j int)      { k.keys[i], k.keys[j] = k.keys[j], k.keys[i] }
+
+// SetMany sets the values associated with all the keys in pairs. If a
+// key appears more than once, the last value wins. It returns the
+// number of keys that were inserted and the number of keys that were
+// already present, so that every distinct key is counted once.
+//
+// SetMany sorts a copy of pairs first, and then goes through s once,
+// so that the search for every key starts from the path to the
+// previous one. It panics if any of the keys is nil, without
+// modifying s.
+func (s *SkipList) SetMany(pairs []Pair) (inserted, updated int) {
+	sorted := make([]Pair, len(pairs))
+	copy(sorted, pairs)
+	for _, pair := range sorted {
+		if pair.Key == nil {
+			panic("goskiplist: nil keys are not supported")
+		}
+	}
+	sort.Stable(pairSorter{sorted, s.lessThan})
+	// Keep only the last pair for every key.
+	unique := sorted[:0]
+	for i, pair := range sorted {
+		if i+1 < len(sorted) && s.keysEqual(pair.Key, sorted[i+1].Key) {
+			continue
+		}
+		unique = append(unique, pair)
+	}
+
+	w := s.newBatchWalker()
+	for _, pair := range unique {
+		if s.checker != nil {
+			s.checker.observe(pair.Key)
+		}
+		candidate := w.seek(pair.Key)
+		if candidate != nil && s.keysEqual(candidate.key, pair.Key) {
+			if s.hidden(candidate) {
+				inserted++
+			} else {
+				updated++
+			}
+			s.replace(candidate, pair.Value)
+			continue
+		}
+		w.insert(pair.Key, pair.Value)
+		inserted++
+	}
+	w.done()
+	return inserted, updated
+}
+
+// DeleteMany removes all the given keys from s. It returns the number
+// of keys that were present. Like SetMany, it sorts the keys first and
+// goes through s once. It panics if any of the keys is nil, without
+// modifying s.
+func (s *SkipList) DeleteMany(keys []interface{}) (deleted int) {
+	sorted := make([]interface{}, len(keys))
+	copy(sorted, keys)
+	for _, key := range sorted {
+		if key == nil {
+			panic("goskiplist: nil keys are not supported")
+		}
+	}
+	sort.Sort(keySorter{sorted, s.lessThan})
+
+	w := s.newBatchWalker()
+	for _, key := range sorted {
+		candidate := w.seek(key)
+		found := candidate != nil && s.keysEqual(candidate.key, key)
+		switch {
+		case found && s.hidden(candidate):
+		case found && s.ranges != nil:
+			s.replace(candidate, tombstone{})
+			deleted++
+		case found:
+			w.remove(candidate)
+			deleted++
+		case s.ranges != nil:
+			// Like Delete, record a tombstone for the absent
+			// key.
+			w.insert(key, tombstone{})
+		}
+	}
+	w.done()
+	return deleted
+}
+
+// A batchWalker goes through a skip list in key order, keeping the
+// update vector of the last key it looked for.
+type batchWalker struct {
+	list   *SkipList
+	update []*node
+}
+
+func (s *SkipList) newBatchWalker() *batchWalker {
+	w := &batchWalker{list: s}
+	w.reset()
+	return w
+}
+
+// reset points the update vector at the header.
+func (w *batchWalker) reset() {
+	s := w.list
+	w.update = w.update[:0]
+	for i := 0; i <= s.level(); i++ {
+		w.update = append(w.update, s.header)
+	}
+}
+
+// seek fills the update vector for key, which must not be less than
+// the previous key, and returns the first node whose key is not less
+// than key. Like a search from the finger, it climbs up the update
+// vector only as long as the next node precedes key, and then
+// descends from there.
+func (w *batchWalker) seek(key interface{}) *node {
+	s := w.list
+	level := 0
+	for level < s.level() {
+		next := w.update[level].forward[level]
+		if next == nil || !s.lessThan(next.key, key) {
+			break
+		}
+		level++
+	}
+	return s.descend(w.update[level], level, w.update, key)
+}
+
+// insert inserts a node for the last key that was looked for.
+func (w *batchWalker) insert(key, value interface{}) {
+	s := w.list
+	s.insert(w.update, key, value)
+	if s.deterministic {
+		// Splitting gaps moves towers around.
+		w.reset()
+		return
+	}
+	for len(w.update) <= s.level() {
+		// insert may have added levels to the header.
+		w.update = append(w.update, s.header)
+	}
+}
+
+// remove removes candidate, which must be the node of the last key
+// that was looked for.
+func (w *batchWalker) remove(candidate *node) {
+	s := w.list
+	s.remove(w.update, candidate)
+	if s.deterministic {
+		// Merging gaps moves towers around.
+		w.reset()
+		return
+	}
+	w.update = w.update[:s.level()+1]
+}
+
+// done leaves the update vector as the finger of the list.
+func (w *batchWalker) done() {
+	w.list.setFinger(w.update)
+}
+
+// AddMany adds all the given keys to s. It returns the number of keys
+// that were not present before. See SkipList.SetMany.
+func (s *Set) AddMany(keys []interface{}) (added int) {
+	pairs := make([]Pair, len(keys))
+	for i, key := range keys {
+		pairs[i].Key = key
+	}
+	added, _ = s.skiplist.SetMany(pairs)
+	return added
+}
+
+// RemoveMany removes all the given keys from s. It returns the number
+// of keys that were present. See SkipList.DeleteMany.
+func (s *Set) RemoveMany(keys []interface{}) (removed int) {
+	return s.skiplist.DeleteMany(keys)
+}
@@ -0,0 +1,131 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSetMany(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 100; i += 2 {
+		s.Set(i, i)
+	}
+
+	var pairs []Pair
+	for i := 99; i >= 0; i-- {
+		pairs = append(pairs, Pair{i, -i})
+	}
+	pairs = append(pairs, Pair{50, 1000}, Pair{101, 101})
+
+	inserted, updated := s.SetMany(pairs)
+	// 50 appears twice, but it is counted once.
+	if inserted != 51 || updated != 50 {
+		t.Errorf("SetMany should have inserted 51 and updated 50 keys, not %v and %v.", inserted, updated)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+	if pairs[0].Key != 99 {
+		t.Errorf("SetMany modified its argument.")
+	}
+	s.check(t, 50, 1000)
+	if inserted, updated := s.SetMany([]Pair{{200, 1}, {200, 2}, {200, 3}}); inserted != 1 || updated != 0 {
+		t.Errorf("A new key set three times should count as 1 insertion, not %v and %v updates.", inserted, updated)
+	}
+	s.check(t, 200, 3)
+	s.Delete(200)
+	s.check(t, 99, -99)
+	s.check(t, 101, 101)
+
+	deleted := s.DeleteMany([]interface{}{101, 3, 3, 1000, 0})
+	if deleted != 3 || s.Len() != 98 {
+		t.Errorf("DeleteMany should have deleted 3 keys, not %v (Len %v).", deleted, s.Len())
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("SetMany with a nil key should have panicked.")
+			}
+		}()
+		s.SetMany([]Pair{{1000, 0}, {nil, 0}})
+	}()
+	if _, ok := s.Get(1000); ok {
+		t.Errorf("SetMany with a nil key shouldn't modify the list.")
+	}
+}
+
+func TestAddMany(t *testing.T) {
+	set := NewIntSet()
+	if added := set.AddMany([]interface{}{3, 1, 2, 3}); added != 3 {
+		t.Errorf("AddMany should have added 3 keys, not %v.", added)
+	}
+	if removed := set.RemoveMany([]interface{}{1, 5}); removed != 1 || set.Len() != 2 {
+		t.Errorf("RemoveMany should have removed 1 key, not %v (Len %v).", removed, set.Len())
+	}
+}
+
+func TestSetManyRandom(t *testing.T) {
+	for _, s := range []*SkipList{NewIntMap(), NewDeterministicMap(intLessThan)} {
+		reference := make(map[int]int)
+		for round := 0; round < 20; round++ {
+			var pairs []Pair
+			var keys []interface{}
+			for i := 0; i < 200; i++ {
+				key := rand.Intn(1000)
+				pairs = append(pairs, Pair{key, round})
+				keys = append(keys, rand.Intn(1000))
+			}
+			s.SetMany(pairs)
+			for _, pair := range pairs {
+				reference[pair.Key.(int)] = round
+			}
+			s.DeleteMany(keys)
+			for _, key := range keys {
+				delete(reference, key.(int))
+			}
+			if err := s.Verify(); err != nil {
+				t.Fatalf("Verify failed in round %d: %v.", round, err)
+			}
+		}
+		if s.Len() != len(reference) {
+			t.Errorf("Len should be %d, not %d.", len(reference), s.Len())
+		}
+		for key, value := range reference {
+			s.check(t, key, value)
+		}
+	}
+}
+
+func makeBatch(n int) []Pair {
+	pairs := make([]Pair, n)
+	for i := range pairs {
+		key := rand.Int()
+		pairs[i] = Pair{key, key}
+	}
+	return pairs
+}
+
+func BenchmarkSetMany(b *testing.B) {
+	b.StopTimer()
+	s := makeRandomList(65536)
+	batch := makeBatch(b.N)
+	b.StartTimer()
+	s.SetMany(batch)
+}
+
+func BenchmarkSetBatchOneByOne(b *testing.B) {
+	b.StopTimer()
+	s := makeRandomList(65536)
+	batch := makeBatch(b.N)
+	b.StartTimer()
+	for _, pair := range batch {
+		s.Set(pair.Key, pair.Value)
+	}
+}
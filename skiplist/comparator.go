@@ -0,0 +1,152 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// maxViolations is the maximum number of violations remembered by a
+// skip list with comparator checking enabled.
+const maxViolations = 100
+
+// A ComparatorViolation describes a set of keys for which a comparison
+// function fails to define a linear order.
+type ComparatorViolation struct {
+	// Property is the property that doesn't hold. It is one of
+	// "irreflexivity" (x < x), "asymmetry" (x < y and y < x),
+	// "transitivity" (x < y and y < z, but not x < z) and "equality"
+	// (neither x < y nor y < x, but x != y, or the other way
+	// round).
+	Property string
+	// Keys are the offending keys, in the order used in the
+	// description of Property.
+	Keys []interface{}
+}
+
+func (v ComparatorViolation) Error() string {
+	return fmt.Sprintf("goskiplist: comparison function violates %s for keys %v", v.Property, v.Keys)
+}
+
+// CheckComparator checks whether lessThan defines a linear order on
+// samples that is consistent with ==, which is used to find equal
+// keys. It checks all the pairs and triples of samples, so it takes
+// O(n^3) time. The samples must be comparable using ==.
+func CheckComparator(lessThan func(l, r interface{}) bool, samples []interface{}) []ComparatorViolation {
+	c := &comparatorChecker{
+		lessThan: lessThan,
+		equal: func(l, r interface{}) bool {
+			return l == r
+		},
+	}
+	for _, key := range samples {
+		c.check(key, c.samples)
+		c.samples = append(c.samples, key)
+	}
+	return c.violations
+}
+
+// comparatorChecker checks the comparison function of a skip list
+// against a random sample of the keys that were set.
+type comparatorChecker struct {
+	lessThan   func(l, r interface{}) bool
+	equal      func(l, r interface{}) bool
+	samples    []interface{}
+	size       int
+	seen       int
+	violations []ComparatorViolation
+}
+
+func (c *comparatorChecker) report(property string, keys ...interface{}) {
+	if len(c.violations) < maxViolations {
+		c.violations = append(c.violations, ComparatorViolation{property, keys})
+	}
+}
+
+// check checks key against all the pairs of others.
+func (c *comparatorChecker) check(key interface{}, others []interface{}) {
+	if c.lessThan(key, key) {
+		c.report("irreflexivity", key)
+	}
+	for i, a := range others {
+		c.checkPair(key, a)
+		for _, b := range others[i+1:] {
+			c.checkTriple(key, a, b)
+			c.checkTriple(key, b, a)
+			c.checkTriple(a, key, b)
+			c.checkTriple(b, key, a)
+			c.checkTriple(a, b, key)
+			c.checkTriple(b, a, key)
+		}
+	}
+}
+
+func (c *comparatorChecker) checkPair(x, y interface{}) {
+	less, greater := c.lessThan(x, y), c.lessThan(y, x)
+	if less && greater {
+		c.report("asymmetry", x, y)
+	}
+	if equal := c.equal(x, y); equal != (!less && !greater) {
+		c.report("equality", x, y)
+	}
+}
+
+func (c *comparatorChecker) checkTriple(x, y, z interface{}) {
+	if c.lessThan(x, y) && c.lessThan(y, z) && !c.lessThan(x, z) {
+		c.report("transitivity", x, y, z)
+	}
+}
+
+// observe checks key against the sample, and then uses reservoir
+// sampling to decide whether key should become a part of the sample.
+func (c *comparatorChecker) observe(key interface{}) {
+	c.check(key, c.samples)
+	c.seen++
+	if len(c.samples) < c.size {
+		c.samples = append(c.samples, key)
+	} else if i := rand.Intn(c.seen); i < c.size {
+		c.samples[i] = key
+	}
+}
+
+// EnableComparatorCheck makes s check its comparison function during
+// Set: every new key is checked against all the pairs of keys from a
+// random sample of size sampleSize (chosen among the keys that were
+// set before). This makes Set take O(sampleSize^2) additional time, so
+// it should only be used for debugging. Violations can be retrieved
+// using ComparatorViolations. A sampleSize of 0 disables checking.
+func (s *SkipList) EnableComparatorCheck(sampleSize int) {
+	if sampleSize <= 0 {
+		s.checker = nil
+		return
+	}
+	s.checker = &comparatorChecker{
+		lessThan: s.lessThan,
+		equal:    s.keysEqual,
+		size:     sampleSize,
+	}
+}
+
+// ComparatorViolations returns the violations found since comparator
+// checking was enabled (see EnableComparatorCheck). At most 100
+// violations are remembered.
+func (s *SkipList) ComparatorViolations() []ComparatorViolation {
+	if s.checker == nil {
+		return nil
+	}
+	return s.checker.violations
+}
+
+// EnableComparatorCheck enables checking the comparison function of
+// s. See SkipList.EnableComparatorCheck.
+func (s *Set) EnableComparatorCheck(sampleSize int) {
+	s.skiplist.EnableComparatorCheck(sampleSize)
+}
+
+// ComparatorViolations returns the violations of the comparison
+// function found in s. See SkipList.ComparatorViolations.
+func (s *Set) ComparatorViolations() []ComparatorViolation {
+	return s.skiplist.ComparatorViolations()
+}
@@ -0,0 +1,88 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"testing"
+)
+
+// rockPaperScissors is not transitive: 0 < 1 < 2 < 0.
+func rockPaperScissors(l, r interface{}) bool {
+	return (r.(int)-l.(int)+3)%3 == 1
+}
+
+func hasViolation(violations []ComparatorViolation, property string) bool {
+	for _, v := range violations {
+		if v.Property == property {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckComparator(t *testing.T) {
+	intLess := func(l, r interface{}) bool {
+		return l.(int) < r.(int)
+	}
+	if v := CheckComparator(intLess, []interface{}{3, 1, 2, 1, 5}); len(v) != 0 {
+		t.Errorf("CheckComparator found violations for a correct comparison function: %v.", v)
+	}
+
+	v := CheckComparator(rockPaperScissors, []interface{}{0, 1, 2})
+	if !hasViolation(v, "transitivity") {
+		t.Errorf("CheckComparator should have found a transitivity violation, got %v.", v)
+	}
+
+	v = CheckComparator(func(l, r interface{}) bool {
+		return l.(int) <= r.(int)
+	}, []interface{}{1, 2})
+	if !hasViolation(v, "irreflexivity") {
+		t.Errorf("CheckComparator should have found an irreflexivity violation, got %v.", v)
+	}
+
+	v = CheckComparator(func(l, r interface{}) bool {
+		return l.(int)%2 != r.(int)%2
+	}, []interface{}{1, 2})
+	if !hasViolation(v, "asymmetry") {
+		t.Errorf("CheckComparator should have found an asymmetry violation, got %v.", v)
+	}
+
+	v = CheckComparator(func(l, r interface{}) bool {
+		return l.(int)/10 < r.(int)/10
+	}, []interface{}{1, 2, 13})
+	if !hasViolation(v, "equality") {
+		t.Errorf("CheckComparator should have found an equality violation, got %v.", v)
+	}
+	if len(v) != 1 || v[0].Keys[0] != 2 || v[0].Keys[1] != 1 {
+		t.Errorf("CheckComparator reported the wrong keys: %v.", v)
+	}
+}
+
+func TestEnableComparatorCheck(t *testing.T) {
+	s := NewIntMap()
+	s.EnableComparatorCheck(8)
+	for i := 0; i < 100; i++ {
+		s.Set(i, i)
+	}
+	if v := s.ComparatorViolations(); len(v) != 0 {
+		t.Errorf("Violations found for a correct comparison function: %v.", v)
+	}
+
+	set := NewCustomSet(rockPaperScissors)
+	set.EnableComparatorCheck(8)
+	set.Add(0)
+	set.Add(1)
+	if v := set.ComparatorViolations(); len(v) != 0 {
+		t.Errorf("Violations found too early: %v.", v)
+	}
+	set.Add(2)
+	if v := set.ComparatorViolations(); !hasViolation(v, "transitivity") {
+		t.Errorf("A transitivity violation should have been found, got %v.", v)
+	}
+
+	set.EnableComparatorCheck(0)
+	if v := set.ComparatorViolations(); v != nil {
+		t.Errorf("Disabling checking should forget violations, got %v.", v)
+	}
+}
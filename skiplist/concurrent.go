@@ -0,0 +1,97 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"sync"
+)
+
+// A ConcurrentMap is a SkipList guarded by a read-write mutex, so that
+// it can be used from many goroutines at the same time. Get and Len
+// can run concurrently; every other method holds the mutex for
+// writing during the whole operation, so Update, GetOrSet,
+// CompareAndSwap and CompareAndDelete are atomic. This makes it
+// possible to build counters or deduplication tables on top of it.
+type ConcurrentMap struct {
+	mu   sync.RWMutex
+	list *SkipList
+}
+
+// NewConcurrentMap returns a new ConcurrentMap that will use lessThan
+// as the comparison function.
+func NewConcurrentMap(lessThan func(l, r interface{}) bool) *ConcurrentMap {
+	return &ConcurrentMap{list: NewCustomMap(lessThan)}
+}
+
+// Len returns the length of m.
+func (m *ConcurrentMap) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.list.Len()
+}
+
+// Get returns the value associated with key from m (nil if the key is
+// not present in m). The second return value is true when the key is
+// present.
+func (m *ConcurrentMap) Get(key interface{}) (value interface{}, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.list.Get(key)
+}
+
+// Set sets the value associated with key in m.
+func (m *ConcurrentMap) Set(key, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.list.Set(key, value)
+}
+
+// Delete removes the node with the given key. It returns the old value
+// and whether the node was present.
+func (m *ConcurrentMap) Delete(key interface{}) (value interface{}, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list.Delete(key)
+}
+
+// Update atomically replaces the value associated with key by the one
+// returned by f, or removes key. See SkipList.Update. f is called with
+// the mutex held, so it must not use m.
+func (m *ConcurrentMap) Update(key interface{}, f func(old interface{}, exists bool) (new interface{}, keep bool)) (value interface{}, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list.Update(key, f)
+}
+
+// GetOrSet atomically returns the value associated with key, or sets
+// it to value if key is not present. See SkipList.GetOrSet.
+func (m *ConcurrentMap) GetOrSet(key, value interface{}) (actual interface{}, loaded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list.GetOrSet(key, value)
+}
+
+// CompareAndSwap atomically replaces the value associated with key by
+// new, if it is old. See SkipList.CompareAndSwap.
+func (m *ConcurrentMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list.CompareAndSwap(key, old, new)
+}
+
+// CompareAndDelete atomically removes key, if its value is old. See
+// SkipList.CompareAndDelete.
+func (m *ConcurrentMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list.CompareAndDelete(key, old)
+}
+
+// Freeze returns a FrozenMap containing the elements of m. See
+// SkipList.Freeze.
+func (m *ConcurrentMap) Freeze() *FrozenMap {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.list.Freeze()
+}
@@ -0,0 +1,104 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrentMap(t *testing.T) {
+	const (
+		goroutines = 8
+		rounds     = 500
+		counters   = 10
+	)
+	m := NewConcurrentMap(intLessThan)
+	increment := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+
+	var wg sync.WaitGroup
+	// owners[i] is the number of goroutines that won key 1000+i.
+	owners := make([]int, rounds)
+	var ownersMu sync.Mutex
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				// Counters built on Update.
+				m.Update(i%counters, increment)
+
+				// Counters built on CompareAndSwap.
+				for {
+					old, ok := m.Get(100)
+					if !ok {
+						if _, loaded := m.GetOrSet(100, 1); !loaded {
+							break
+						}
+						continue
+					}
+					if m.CompareAndSwap(100, old, old.(int)+1) {
+						break
+					}
+				}
+
+				// Deduplication built on GetOrSet.
+				if _, loaded := m.GetOrSet(1000+i, g); !loaded {
+					ownersMu.Lock()
+					owners[i]++
+					ownersMu.Unlock()
+				}
+				m.Set(2000+g, i)
+				m.Delete(2000 + g)
+				m.Len()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i := 0; i < counters; i++ {
+		if value, _ := m.Get(i); value != goroutines*rounds/counters {
+			t.Errorf("Counter %v should be %v, not %v.", i, goroutines*rounds/counters, value)
+		}
+	}
+	if value, _ := m.Get(100); value != goroutines*rounds {
+		t.Errorf("The CompareAndSwap counter should be %v, not %v.", goroutines*rounds, value)
+	}
+	for i, n := range owners {
+		if n != 1 {
+			t.Errorf("Key %v should have been set by exactly one goroutine, not %v.", 1000+i, n)
+		}
+	}
+
+	// Only one goroutine can delete each key.
+	var deleted [rounds]int
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				value, ok := m.Get(1000 + i)
+				if ok && m.CompareAndDelete(1000+i, value) {
+					ownersMu.Lock()
+					deleted[i]++
+					ownersMu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, n := range deleted {
+		if n != 1 {
+			t.Errorf("Key %v should have been deleted exactly once, not %v times.", 1000+i, n)
+		}
+	}
+	if m.Len() != counters+1 || m.Freeze().Len() != counters+1 {
+		t.Errorf("Len should be %v, not %v.", counters+1, m.Len())
+	}
+}
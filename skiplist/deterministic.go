@@ -0,0 +1,212 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"fmt"
+)
+
+// Deterministic skip lists are described in Munro, J. Ian; Papadakis,
+// Thomas; Sedgewick, Robert (1992). "Deterministic skip lists".
+// Proceedings of the third annual ACM-SIAM Symposium on Discrete
+// algorithms (SODA '92): 367–375.
+//
+// Two nodes are linked at level i if both their towers are higher
+// than i. The nodes between two such nodes whose towers have height
+// exactly i form a gap. In a 1-2-3 skip list every gap has between
+// minGap and maxGap nodes (the gap at the highest level, between the
+// header and the end of the list, may not be empty either). This
+// makes the list equivalent to a 2-3-4 tree: gaps which become too
+// big after an insertion are split by raising their middle node, and
+// gaps which become empty after a deletion borrow a node from, or are
+// merged with, one of their siblings.
+
+const (
+	minGap = 1
+	maxGap = 3
+)
+
+// NewDeterministicMap returns a new SkipList that will use lessThan as
+// the comparison function, and that is balanced deterministically (as
+// a 1-2-3 skip list) instead of using random levels. Searches,
+// insertions and deletions take O(log n) time in the worst case, no
+// matter the order of the keys. MaxLevel and AutoMaxLevel are ignored,
+// as are calls to Rebalance and Compact.
+func NewDeterministicMap(lessThan func(l, r interface{}) bool) *SkipList {
+	s := NewCustomMap(lessThan)
+	s.deterministic = true
+	return s
+}
+
+// NewDeterministicSet returns a new Set that will use lessThan as the
+// comparison function, and that is backed by a deterministic skip list
+// (see NewDeterministicMap).
+func NewDeterministicSet(lessThan func(l, r interface{}) bool) *Set {
+	return &Set{skiplist: *NewDeterministicMap(lessThan)}
+}
+
+// boundary returns the node preceding a gap at the given level, given
+// an update vector for a key inside that gap.
+func (s *SkipList) boundary(update []*node, level int) *node {
+	if level+1 < len(update) && level+1 <= s.level() {
+		return update[level+1]
+	}
+	return s.header
+}
+
+// gapSize returns the number of nodes linked at the given level
+// between from and to (exclusive).
+func gapSize(from, to *node, level int) (size int) {
+	for current := from.forward[level]; current != to; current = current.forward[level] {
+		size++
+	}
+	return size
+}
+
+// insertDeterministic inserts a new node with a tower of height 1,
+// and then splits the gaps that became too big, going up.
+func (s *SkipList) insertDeterministic(update []*node, key, value interface{}) *node {
+	previous := update[0]
+	newNode := &node{
+		forward: []*node{previous.forward[0]},
+		key:     key,
+		value:   value,
+	}
+	previous.forward[0] = newNode
+	if previous != s.header {
+		newNode.backward = previous
+	}
+	if next := newNode.next(); next != nil {
+		next.backward = newNode
+	} else {
+		s.footer = newNode
+	}
+	s.length++
+
+	for level := 0; ; level++ {
+		left := s.boundary(update, level)
+		var right *node
+		if level+1 < len(left.forward) {
+			right = left.forward[level+1]
+		}
+		if gapSize(left, right, level) <= maxGap {
+			break
+		}
+
+		// Raise the second node of the gap, leaving gaps of
+		// sizes 1 and 2.
+		middle := left.forward[level].forward[level]
+		if level == s.level() {
+			s.header.forward = append(s.header.forward, nil)
+		}
+		middle.forward = append(middle.forward, left.forward[level+1])
+		left.forward[level+1] = middle
+	}
+
+	return newNode
+}
+
+// removeDeterministic removes candidate, and then fixes the gaps that
+// became empty, going up.
+func (s *SkipList) removeDeterministic(update []*node, candidate *node) {
+	if len(candidate.forward) > 1 {
+		// Only nodes of height 1 can be removed without breaking
+		// the gaps above them. The predecessor of a higher node
+		// always has height 1, as it belongs to a non-empty gap
+		// at level 0, so its node takes over the tower of
+		// candidate. Keys never move to other nodes, so
+		// iterators on them stay valid.
+		predecessor := candidate.backward
+		predecessor.forward = candidate.forward
+		for i := 1; i < len(candidate.forward); i++ {
+			update[i].forward[i] = predecessor
+		}
+		if next := predecessor.next(); next != nil {
+			next.backward = predecessor
+		} else {
+			s.footer = predecessor
+		}
+	} else {
+		update[0].forward[0] = candidate.forward[0]
+		if next := candidate.next(); next != nil {
+			next.backward = candidate.backward
+		} else {
+			s.footer = candidate.backward
+		}
+	}
+	s.length--
+
+	for level := 0; level < s.level(); level++ {
+		left := update[level+1]
+		right := left.forward[level+1]
+		if gapSize(left, right, level) >= minGap {
+			break
+		}
+
+		if right != nil && len(right.forward) == level+2 {
+			// Lower right, merging the gap with its right
+			// sibling.
+			sibling := right.forward[level+1]
+			size := gapSize(right, sibling, level)
+			left.forward[level+1] = sibling
+			right.forward = right.forward[:level+1]
+			if size > minGap {
+				// The sibling can spare a node: raise the
+				// node after right, so that right alone
+				// fills the gap.
+				middle := right.forward[level]
+				middle.forward = append(middle.forward, sibling)
+				left.forward[level+1] = middle
+				break
+			}
+			continue
+		}
+
+		// The left boundary of the gap can't be the header, as
+		// otherwise the gap above would be empty.
+		leftLeft := s.boundary(update, level+1)
+		for leftLeft.forward[level+1] != left {
+			leftLeft = leftLeft.forward[level+1]
+		}
+		size := gapSize(leftLeft, left, level)
+		last := leftLeft
+		for last.forward[level] != left {
+			last = last.forward[level]
+		}
+		leftLeft.forward[level+1] = right
+		left.forward = left.forward[:level+1]
+		if size > minGap {
+			// Lower left and raise its predecessor instead.
+			last.forward = append(last.forward, right)
+			leftLeft.forward[level+1] = last
+			break
+		}
+	}
+
+	s.trimLevels()
+}
+
+// verifyGaps checks that all the gaps of a deterministic skip list
+// have the right sizes.
+func (s *SkipList) verifyGaps() error {
+	for level := 0; level <= s.level(); level++ {
+		size := 0
+		var boundary interface{}
+		for current := s.header.forward[level]; ; current = current.forward[level] {
+			if current == nil || len(current.forward) > level+1 {
+				empty := s.length == 0 && current == nil && boundary == nil
+				if (size < minGap && !empty) || size > maxGap {
+					return &VerifyError{level, boundary, fmt.Sprintf("the gap after this node has %d nodes", size)}
+				}
+				if current == nil {
+					break
+				}
+				boundary, size = current.key, 0
+				continue
+			}
+			size++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func intLessThan(l, r interface{}) bool {
+	return l.(int) < r.(int)
+}
+
+func TestDeterministicRandomOperations(t *testing.T) {
+	s := NewDeterministicMap(intLessThan)
+	reference := make(map[int]int)
+
+	for i := 0; i < 5000; i++ {
+		key := rand.Intn(500)
+		if rand.Intn(3) == 0 {
+			v, ok := s.Delete(key)
+			if expected, present := reference[key]; ok != present || (ok && v != expected) {
+				t.Fatalf("Delete(%d) returned %v, %v instead of %v, %v.", key, v, ok, expected, present)
+			}
+			delete(reference, key)
+		} else {
+			s.Set(key, i)
+			reference[key] = i
+		}
+		if err := s.Verify(); err != nil {
+			t.Fatalf("Verify failed after operation %d on key %d: %v.", i, key, err)
+		}
+	}
+
+	if s.Len() != len(reference) {
+		t.Errorf("Len should be %d, not %d.", len(reference), s.Len())
+	}
+	for key, value := range reference {
+		s.check(t, key, value)
+	}
+}
+
+func TestDeterministicWorstCase(t *testing.T) {
+	s := NewDeterministicMap(intLessThan)
+	const n = 1 << 14
+	// Ascending insertions are the worst case for many balancing
+	// schemes.
+	for i := 0; i < n; i++ {
+		s.Set(i, i)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+
+	// Every gap has at least one node, so the level is at most
+	// log_2(n).
+	if l := s.level(); l > int(math.Log2(n)) {
+		t.Errorf("The level is %d, which is more than log_2(%d).", l, n)
+	}
+	maxCost := (s.level() + 1) * (maxGap + 1)
+	for i := 0; i < n; i++ {
+		if cost := s.searchCost(i); cost > maxCost {
+			t.Errorf("Looking for %d took %d steps (more than %d).", i, cost, maxCost)
+		}
+	}
+
+	for i := 0; i < n; i += 2 {
+		s.Delete(i)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+	for i := 1; i < n; i += 2 {
+		s.check(t, i, i)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		s.Delete(i)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+	if s.Len() != 0 || s.level() != 0 || s.SeekToFirst() != nil {
+		t.Errorf("The list should be empty (Len %d, level %d).", s.Len(), s.level())
+	}
+}
+
+func TestDeterministicSet(t *testing.T) {
+	set := NewDeterministicSet(intLessThan)
+	for i := 0; i < 100; i++ {
+		set.Add(i)
+	}
+	set.Rebalance()
+	set.Compact()
+	if err := set.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+
+	s := &set.skiplist
+	if n := s.deleteRange(10, 90); n != 80 || set.Len() != 20 {
+		t.Errorf("deleteRange(10, 90) removed %d elements (Len %d).", n, set.Len())
+	}
+	if err := set.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+}
+
+func TestDeterministicDeleteKeepsIterators(t *testing.T) {
+	s := NewDeterministicMap(intLessThan)
+	for i := 0; i < 100; i++ {
+		s.Set(i, i)
+	}
+	// Deleting k moves the tower of k to the node of k-1, if k has
+	// one; the iterator on 0 has to see the other keys unchanged.
+	for k := 1; k < 99; k++ {
+		i := s.Seek(0)
+		s.Delete(k)
+		if !i.Next() || i.Key() != k+1 || i.Value() != k+1 {
+			t.Fatalf("After deleting %d, the next key after 0 should be %d, not %v.", k, k+1, i.Key())
+		}
+		if !i.Previous() || i.Key() != 0 {
+			t.Fatalf("After deleting %d, the key before %d should be 0, not %v.", k, k+1, i.Key())
+		}
+		if err := s.Verify(); err != nil {
+			t.Fatalf("Verify failed after deleting %d: %v.", k, err)
+		}
+	}
+
+	s = NewDeterministicMap(intLessThan)
+	for i := 0; i < 100; i++ {
+		s.Set(i, i)
+	}
+	i := s.Seek(50)
+	for k := 0; k < 100; k++ {
+		if k != 50 {
+			s.Delete(k)
+		}
+	}
+	if i.Key() != 50 || i.Value() != 50 || i.Next() || s.SeekToFirst().Key() != 50 {
+		t.Errorf("The iterator should stay on 50, the only key left.")
+	}
+}
+
+func TestVerifyGaps(t *testing.T) {
+	s := NewDeterministicMap(intLessThan)
+	for i := 0; i < 100; i++ {
+		s.Set(i, i)
+	}
+	n := s.header.forward[1]
+	n.forward = n.forward[:1]
+	s.header.forward[1] = n.next()
+	for s.header.forward[1] != nil && len(s.header.forward[1].forward) < 2 {
+		s.header.forward[1] = s.header.forward[1].next()
+	}
+	if err := s.Verify(); err == nil {
+		t.Errorf("Verify should have noticed a gap that is too big.")
+	}
+}
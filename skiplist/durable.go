@@ -0,0 +1,364 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrCorrupt is returned when a file contains a well-formed record
+// that can't be decoded, or when a snapshot is incomplete.
+var ErrCorrupt = errors.New("goskiplist: corrupt file")
+
+// errTorn is returned by readRecord for an incomplete record, an empty
+// one (which is what a tail of zeros looks like), or a record whose
+// checksum doesn't match.
+var errTorn = errors.New("goskiplist: torn record")
+
+const (
+	opSet    byte = 1
+	opDelete byte = 2
+
+	// recordHeaderSize is the size of the length and the checksum
+	// preceding the payload of every record.
+	recordHeaderSize = 8
+	// maxRecordSize bounds the length of a record, so that a
+	// corrupt length isn't trusted.
+	maxRecordSize = 1 << 30
+
+	logName      = "log"
+	snapshotName = "snapshot"
+)
+
+// A DurableMap is a SkipList whose modifications are written to a log
+// file before they are applied, so that its contents survive a crash.
+// Every record of the log holds its length and its CRC-32 checksum, so
+// that a record torn by a crash is detected (and discarded) when the
+// map is opened again. Checkpoint writes the contents of the map to a
+// snapshot file, and empties the log.
+//
+// Modifications are written to the operating system, but not synced to
+// the disk; call Sync to make them durable.
+type DurableMap struct {
+	list         *SkipList
+	keys, values Codec
+	dir          string
+	log          *os.File
+	// offset is the size of the valid part of the log.
+	offset int64
+}
+
+// OpenDurableMap opens the durable map stored in directory dir, which
+// must exist, creating it if it is empty. The map uses lessThan as the
+// comparison function, and keys and values to serialize keys and
+// values.
+func OpenDurableMap(dir string, lessThan func(l, r interface{}) bool, keys, values Codec) (*DurableMap, error) {
+	m := &DurableMap{
+		list:   NewCustomMap(lessThan),
+		keys:   keys,
+		values: values,
+		dir:    dir,
+	}
+
+	if snapshot, err := os.Open(filepath.Join(dir, snapshotName)); err == nil {
+		valid, err := m.replay(snapshot)
+		size, statErr := snapshot.Seek(0, 2)
+		snapshot.Close()
+		if err != nil {
+			return nil, err
+		}
+		if statErr != nil {
+			return nil, statErr
+		}
+		if valid != size {
+			// Snapshots are renamed into place once complete.
+			return nil, ErrCorrupt
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, logName), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	m.log = log
+	if m.offset, err = m.replay(log); err == nil {
+		err = m.truncateLog()
+	}
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// replay applies the records read from r to the list, and returns the
+// size of the valid records. It stops at the first torn record. If
+// the comparison function panics, it returns ErrKeyType or
+// ErrComparator.
+func (m *DurableMap) replay(r io.Reader) (valid int64, err error) {
+	defer recoverComparator(&err)
+	b := bufio.NewReader(r)
+	for {
+		payload, err := readRecord(b)
+		if err == io.EOF || err == errTorn {
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+		op, key, value, err := m.decodeRecord(payload)
+		if err != nil {
+			return valid, err
+		}
+		if op == opSet {
+			m.list.Set(key, value)
+		} else {
+			m.list.Delete(key)
+		}
+		valid += int64(recordHeaderSize + len(payload))
+	}
+}
+
+// truncateLog drops everything after the valid part of the log, and
+// positions the log for appending.
+func (m *DurableMap) truncateLog() error {
+	if err := m.log.Truncate(m.offset); err != nil {
+		return err
+	}
+	_, err := m.log.Seek(m.offset, 0)
+	return err
+}
+
+// readRecord reads the payload of a record from r. It returns io.EOF
+// if there are no more records, and errTorn if the record is
+// incomplete or corrupt.
+func readRecord(r io.Reader) (payload []byte, err error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errTorn
+		}
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	// Every record holds at least an operation. An empty payload
+	// has a valid checksum (0), so it has to be rejected here.
+	if length == 0 || length > maxRecordSize {
+		return nil, errTorn
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errTorn
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errTorn
+	}
+	return payload, nil
+}
+
+// encodeRecord returns a record for the given operation. Its payload
+// is the operation, the length of the encoded key as a varint, the
+// encoded key, and the encoded value (for opSet).
+func (m *DurableMap) encodeRecord(op byte, key, value interface{}) ([]byte, error) {
+	encodedKey, err := m.keys.Encode(key)
+	if err != nil {
+		return nil, err
+	}
+	var encodedValue []byte
+	if op == opSet {
+		if encodedValue, err = m.values.Encode(value); err != nil {
+			return nil, err
+		}
+	}
+
+	record := make([]byte, recordHeaderSize+1+binary.MaxVarintLen64, recordHeaderSize+1+binary.MaxVarintLen64+len(encodedKey)+len(encodedValue))
+	record[recordHeaderSize] = op
+	n := binary.PutUvarint(record[recordHeaderSize+1:], uint64(len(encodedKey)))
+	record = record[:recordHeaderSize+1+n]
+	record = append(record, encodedKey...)
+	record = append(record, encodedValue...)
+
+	payload := record[recordHeaderSize:]
+	binary.BigEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	return record, nil
+}
+
+// decodeRecord decodes the payload of a record.
+func (m *DurableMap) decodeRecord(payload []byte) (op byte, key, value interface{}, err error) {
+	if len(payload) == 0 {
+		return 0, nil, nil, ErrCorrupt
+	}
+	op = payload[0]
+	keyLength, n := binary.Uvarint(payload[1:])
+	if n <= 0 || uint64(len(payload)-1-n) < keyLength || (op != opSet && op != opDelete) {
+		return 0, nil, nil, ErrCorrupt
+	}
+	encodedKey := payload[1+n : 1+n+int(keyLength)]
+	if key, err = m.keys.Decode(encodedKey); err != nil {
+		return 0, nil, nil, err
+	}
+	if op == opSet {
+		if value, err = m.values.Decode(payload[1+n+int(keyLength):]); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	if key == nil {
+		return 0, nil, nil, ErrCorrupt
+	}
+	return op, key, value, nil
+}
+
+// append writes a record to the log. If the write fails, the log is
+// truncated back to its previous size.
+func (m *DurableMap) append(op byte, key, value interface{}) error {
+	record, err := m.encodeRecord(op, key, value)
+	if err != nil {
+		return err
+	}
+	if _, err := m.log.Write(record); err != nil {
+		m.truncateLog()
+		return err
+	}
+	m.offset += int64(len(record))
+	return nil
+}
+
+// Len returns the length of m.
+func (m *DurableMap) Len() int {
+	return m.list.Len()
+}
+
+// Get returns the value associated with key from m (nil if the key is
+// not present in m). The second return value is true when the key is
+// present.
+func (m *DurableMap) Get(key interface{}) (value interface{}, ok bool) {
+	return m.list.Get(key)
+}
+
+// Iterator returns an Iterator that will go through all elements m.
+// The map must not be modified through the iterator.
+func (m *DurableMap) Iterator() Iterator {
+	return m.list.Iterator()
+}
+
+// Set logs setting the value associated with key in m, and then sets
+// it. If writing to the log fails, m is not modified. Set returns
+// ErrKeyType or ErrComparator, without logging anything, if the
+// comparison function panics for key.
+func (m *DurableMap) Set(key, value interface{}) error {
+	if key == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	// Run the comparison function before logging the key, as a key
+	// that makes it panic would make every replay of the log panic
+	// too. Comparing the key with itself catches keys of the wrong
+	// type even if m is empty.
+	if err := m.list.checkKey(key); err != nil {
+		return err
+	}
+	if _, _, err := m.list.TryGet(key); err != nil {
+		return err
+	}
+	if err := m.append(opSet, key, value); err != nil {
+		return err
+	}
+	m.list.Set(key, value)
+	return nil
+}
+
+// Delete logs the removal of key from m, and then removes it. It
+// returns the old value and whether the key was present. If writing to
+// the log fails, or the comparison function panics, m is not modified.
+func (m *DurableMap) Delete(key interface{}) (value interface{}, ok bool, err error) {
+	if key == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	if err := m.list.checkKey(key); err != nil {
+		return nil, false, err
+	}
+	if _, ok, err := m.list.TryGet(key); !ok || err != nil {
+		return nil, false, err
+	}
+	if err := m.append(opDelete, key, nil); err != nil {
+		return nil, false, err
+	}
+	value, ok = m.list.Delete(key)
+	return value, ok, nil
+}
+
+// Checkpoint writes the contents of m, in key order, to a new snapshot
+// file that atomically replaces the previous one, and then empties the
+// log. A crash between the two steps is harmless: replaying the old
+// log over the new snapshot yields the same contents.
+func (m *DurableMap) Checkpoint() error {
+	path := filepath.Join(m.dir, snapshotName)
+	temporary, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := m.writeSnapshot(temporary); err != nil {
+		temporary.Close()
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := os.Rename(temporary.Name(), path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(m.dir); err == nil {
+		// Make the rename durable, where supported.
+		dir.Sync()
+		dir.Close()
+	}
+
+	m.offset = 0
+	if err := m.truncateLog(); err != nil {
+		return err
+	}
+	return m.log.Sync()
+}
+
+// writeSnapshot writes all the elements of m to f as opSet records,
+// and syncs f.
+func (m *DurableMap) writeSnapshot(f *os.File) error {
+	w := bufio.NewWriter(f)
+	for current := m.list.header.next(); current != nil; current = current.next() {
+		record, err := m.encodeRecord(opSet, current.key, current.value)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Sync commits the log to stable storage.
+func (m *DurableMap) Sync() error {
+	return m.log.Sync()
+}
+
+// Close closes the log. m must not be used afterwards.
+func (m *DurableMap) Close() error {
+	return m.log.Close()
+}
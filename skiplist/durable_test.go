@@ -0,0 +1,209 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openDurable(t *testing.T, dir string) *DurableMap {
+	m, err := OpenDurableMap(dir, intLessThan, GobCodec{}, GobCodec{})
+	if err != nil {
+		t.Fatalf("OpenDurableMap failed: %v.", err)
+	}
+	return m
+}
+
+func (m *DurableMap) contents() map[interface{}]interface{} {
+	contents := make(map[interface{}]interface{})
+	for i := m.Iterator(); i.Next(); {
+		contents[i.Key()] = i.Value()
+	}
+	return contents
+}
+
+func TestDurableMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goskiplist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := openDurable(t, dir)
+	if err := m.Set("bad", "value"); err != ErrKeyType {
+		t.Errorf("Set(\"bad\") on an empty map should return ErrKeyType, not %v.", err)
+	}
+	if _, _, err := m.Delete("bad"); err != ErrKeyType {
+		t.Errorf("Delete(\"bad\") on an empty map should return ErrKeyType, not %v.", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := m.Set(i, "value"); err != nil {
+			t.Fatalf("Set failed: %v.", err)
+		}
+	}
+	if _, ok, err := m.Delete(3); !ok || err != nil {
+		t.Errorf("Delete(3) should succeed, not return %v, %v.", ok, err)
+	}
+	if _, ok, err := m.Delete(30); ok || err != nil {
+		t.Errorf("Delete(30) should return false, nil, not %v, %v.", ok, err)
+	}
+	m.Set(4, "new")
+	if err := m.Set("bad", "value"); err != ErrKeyType {
+		t.Errorf("Set(\"bad\") should return ErrKeyType, not %v.", err)
+	}
+	if _, _, err := m.Delete("bad"); err != ErrKeyType {
+		t.Errorf("Delete(\"bad\") should return ErrKeyType, not %v.", err)
+	}
+	expected := m.contents()
+	m.Close()
+
+	m = openDurable(t, dir)
+	if contents := m.contents(); !reflect.DeepEqual(contents, expected) {
+		t.Errorf("After reopening, the map should contain %v, not %v.", expected, contents)
+	}
+
+	if err := m.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v.", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, logName)); err != nil || info.Size() != 0 {
+		t.Errorf("Checkpoint should have emptied the log (%v, %v).", info, err)
+	}
+	m.Set(100, "after checkpoint")
+	expected[100] = "after checkpoint"
+	m.Close()
+
+	m = openDurable(t, dir)
+	defer m.Close()
+	if contents := m.contents(); !reflect.DeepEqual(contents, expected) {
+		t.Errorf("After a checkpoint, the map should contain %v, not %v.", expected, contents)
+	}
+}
+
+func TestDurableMapBadLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goskiplist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Bypass the checks of Set to log a key of the wrong type.
+	m := openDurable(t, dir)
+	m.Set(1, "one")
+	if err := m.append(opSet, "bad", "value"); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	if _, err := OpenDurableMap(dir, intLessThan, GobCodec{}, GobCodec{}); err != ErrKeyType {
+		t.Errorf("Opening a log with a bad key should return ErrKeyType, not %v.", err)
+	}
+}
+
+func TestDurableMapTornLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goskiplist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Record the contents and the size of the log after every
+	// operation.
+	m := openDurable(t, dir)
+	m.Set(0, "zero")
+	if err := m.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v.", err)
+	}
+	sizes := []int64{0}
+	states := []map[interface{}]interface{}{m.contents()}
+	for i := 0; i < 20; i++ {
+		if i%3 == 2 {
+			m.Delete(i - 1)
+		} else {
+			m.Set(i, i*i)
+		}
+		sizes = append(sizes, m.offset)
+		states = append(states, m.contents())
+	}
+	m.Close()
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, logName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(log)) != sizes[len(sizes)-1] {
+		t.Fatalf("The log should have %v bytes, not %v.", sizes[len(sizes)-1], len(log))
+	}
+
+	crashDir, err := ioutil.TempDir("", "goskiplist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(crashDir)
+	snapshot, err := ioutil.ReadFile(filepath.Join(dir, snapshotName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for offset := 0; offset <= len(log); offset++ {
+		if err := ioutil.WriteFile(filepath.Join(crashDir, snapshotName), snapshot, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(crashDir, logName), log[:offset], 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		complete := 0
+		for complete+1 < len(sizes) && sizes[complete+1] <= int64(offset) {
+			complete++
+		}
+		m := openDurable(t, crashDir)
+		if contents := m.contents(); !reflect.DeepEqual(contents, states[complete]) {
+			t.Fatalf("Log truncated at %v: the map should contain %v, not %v.", offset, states[complete], contents)
+		}
+
+		// The torn tail is dropped, so new records are readable.
+		if err := m.Set(1000, "after crash"); err != nil {
+			t.Fatalf("Set failed: %v.", err)
+		}
+		m.Close()
+		m = openDurable(t, crashDir)
+		if value, ok := m.Get(1000); !ok || value != "after crash" {
+			t.Fatalf("Log truncated at %v: writes after recovery were lost.", offset)
+		}
+		m.Close()
+	}
+
+	// A crash after the log was extended, but before the data was
+	// written, leaves a tail of zeros.
+	for _, complete := range []int{0, 5, len(sizes) - 1} {
+		zeroed := append(append([]byte(nil), log[:sizes[complete]]...), make([]byte, 16)...)
+		if err := ioutil.WriteFile(filepath.Join(crashDir, logName), zeroed, 0666); err != nil {
+			t.Fatal(err)
+		}
+		m := openDurable(t, crashDir)
+		if contents := m.contents(); !reflect.DeepEqual(contents, states[complete]) {
+			t.Errorf("After a tail of zeros, the map should contain %v, not %v.", states[complete], contents)
+		}
+		if m.offset != sizes[complete] {
+			t.Errorf("The tail of zeros should be truncated at %v, not %v.", sizes[complete], m.offset)
+		}
+		m.Close()
+	}
+
+	// A flipped bit is detected by the checksum.
+	corrupt := append([]byte(nil), log...)
+	corrupt[sizes[5]+recordHeaderSize+2] ^= 1
+	if err := ioutil.WriteFile(filepath.Join(crashDir, logName), corrupt, 0666); err != nil {
+		t.Fatal(err)
+	}
+	m = openDurable(t, crashDir)
+	defer m.Close()
+	if contents := m.contents(); !reflect.DeepEqual(contents, states[5]) {
+		t.Errorf("After a corrupt record, the map should contain %v, not %v.", states[5], contents)
+	}
+}
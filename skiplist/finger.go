@@ -0,0 +1,62 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// A search finger is the update vector of the last key that was set
+// or deleted: finger[i] is the last node at level i preceding that
+// key. If finger[0] precedes the next key we are looking for, so do
+// all the other finger nodes, and the search can start from the finger
+// instead of the header. It climbs up the finger only as long as the
+// next node at the current level precedes the key, so a search for a
+// key d positions away from the last one takes O(log d) steps instead
+// of O(log n). This makes sequential insertions much cheaper.
+//
+// The finger doesn't remember the last key itself, only nodes owned by
+// the list: callers may modify their key objects (for example, reuse a
+// []byte buffer) after a call returns.
+//
+// Get uses the finger, but doesn't update it, so concurrent calls to
+// Get remain safe.
+
+// search works like getPath starting from the header, but it starts
+// from the finger if possible.
+func (s *SkipList) search(update []*node, key interface{}) *node {
+	if s.finger == nil || len(s.finger) != s.level()+1 || s.finger[0] != s.header && !s.lessThan(s.finger[0].key, key) {
+		return s.getPath(s.header, update, key)
+	}
+
+	level := 0
+	for level < s.level() {
+		next := s.finger[level].forward[level]
+		if next == nil || !s.lessThan(next.key, key) {
+			break
+		}
+		level++
+	}
+
+	// For all the levels above level, the finger nodes are also
+	// the last nodes preceding key.
+	if update != nil {
+		copy(update, s.finger)
+	}
+	return s.descend(s.finger[level], level, update, key)
+}
+
+// setFinger remembers update as the update vector of the last key.
+// update must have been filled by search or getPath, and s modified
+// only by insert or remove.
+func (s *SkipList) setFinger(update []*node) {
+	if s.deterministic {
+		// Splitting and merging gaps moves towers around.
+		s.finger = nil
+		return
+	}
+
+	s.finger = append(s.finger[:0], update...)
+	for len(s.finger) <= s.level() {
+		// insert may have added levels to the header.
+		s.finger = append(s.finger, s.header)
+	}
+	s.finger = s.finger[:s.level()+1]
+}
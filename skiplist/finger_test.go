@@ -0,0 +1,132 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestFingerSequentialInsertions(t *testing.T) {
+	comparisons := 0
+	s := NewCustomMap(func(l, r interface{}) bool {
+		comparisons++
+		return l.(int) < r.(int)
+	})
+	const n = 1 << 16
+	for i := 0; i < n; i++ {
+		s.Set(i, i)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+
+	// Without the finger every insertion would need about
+	// log_4(n)/p = 32 comparisons.
+	if perInsert := float64(comparisons) / n; perInsert > 8 {
+		t.Errorf("Sequential insertions took %.1f comparisons on average.", perInsert)
+	}
+
+	comparisons = 0
+	for i := 0; i < n; i++ {
+		s.check(t, i, i)
+	}
+	if perGet := float64(comparisons) / n; perGet < 16 {
+		t.Errorf("Get shouldn't move the finger (%.1f comparisons on average).", perGet)
+	}
+
+	comparisons = 0
+	for i := 0; i < n; i++ {
+		if _, ok := s.Delete(i); !ok {
+			t.Fatalf("Delete(%d) failed.", i)
+		}
+	}
+	if perDelete := float64(comparisons) / n; perDelete > 8 {
+		t.Errorf("Sequential deletions took %.1f comparisons on average.", perDelete)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+}
+
+func TestFingerRandomOperations(t *testing.T) {
+	s := NewIntMap()
+	reference := make(map[int]int)
+
+	key := 0
+	for i := 0; i < 20000; i++ {
+		// Mostly small steps, sometimes a jump back.
+		if rand.Intn(10) == 0 {
+			key = rand.Intn(1000)
+		} else {
+			key += rand.Intn(5)
+		}
+
+		switch rand.Intn(4) {
+		case 0:
+			v, ok := s.Delete(key)
+			if expected, present := reference[key]; ok != present || (ok && v != expected) {
+				t.Fatalf("Delete(%d) returned %v, %v instead of %v, %v.", key, v, ok, expected, present)
+			}
+			delete(reference, key)
+		case 1:
+			v, ok := s.Get(key)
+			if expected, present := reference[key]; ok != present || (ok && v != expected) {
+				t.Fatalf("Get(%d) returned %v, %v instead of %v, %v.", key, v, ok, expected, present)
+			}
+		case 2:
+			s.deleteRange(key, key+10)
+			for k := key; k < key+10; k++ {
+				delete(reference, k)
+			}
+		default:
+			s.Set(key, i)
+			reference[key] = i
+		}
+		if i%1000 == 0 {
+			s.Compact()
+		}
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+	for key, value := range reference {
+		s.check(t, key, value)
+	}
+}
+
+func BenchmarkSequentialSet(b *testing.B) {
+	s := NewIntMap()
+	for i := 0; i < b.N; i++ {
+		s.Set(i, i)
+	}
+}
+
+func TestFingerReusedKeyBuffer(t *testing.T) {
+	s := NewBytesMap()
+	for _, key := range []string{"a", "b", "m", "z"} {
+		s.Set([]byte(key), key)
+	}
+
+	// The caller owns buf and may change it after every call.
+	buf := []byte("m")
+	s.Delete(buf)
+	buf[0] = 'a'
+	if value, ok := s.Get([]byte("a")); !ok || value != "a" {
+		t.Errorf("a should be a, not %v.", value)
+	}
+	s.Set([]byte("aa"), "aa")
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+
+	var keys []string
+	for i := s.Iterator(); i.Next(); {
+		keys = append(keys, string(i.Key().([]byte)))
+	}
+	if fmt.Sprint(keys) != "[a aa b z]" {
+		t.Errorf("The keys should be [a aa b z], not %v.", keys)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// A FrozenMap is an immutable copy of a SkipList. Instead of linked
+// nodes, it keeps its keys and values in contiguous arrays, indexed by
+// a perfectly balanced hierarchy of levels (every 1/p-th element of a
+// level is present in the level above it), so lookups take O(log n)
+// time in the worst case and touch little memory.
+//
+// As a FrozenMap cannot be modified, it is safe to use it from many
+// goroutines at the same time without any locking.
+type FrozenMap struct {
+	lessThan func(l, r interface{}) bool
+	equal    func(l, r interface{}) bool
+	keys     []interface{}
+	values   []interface{}
+	// levels[i] indexes the elements present at level i+1 (level 0
+	// being keys itself).
+	levels []frozenLevel
+}
+
+type frozenLevel struct {
+	// positions holds the positions in keys of the elements of this
+	// level.
+	positions []int
+	// below[j] is the index of the j-th element of this level in the
+	// level below.
+	below []int
+}
+
+// Freeze returns a FrozenMap containing the elements of s. Later
+// modifications of s don't affect the returned map. It takes O(n)
+// time.
+func (s *SkipList) Freeze() *FrozenMap {
+	f := &FrozenMap{
+		lessThan: s.lessThan,
+		equal:    s.equal,
+		keys:     make([]interface{}, 0, s.Len()),
+		values:   make([]interface{}, 0, s.Len()),
+	}
+	for current := s.visible(s.header.next()); current != nil; current = s.visible(current.next()) {
+		f.keys = append(f.keys, current.key)
+		f.values = append(f.values, current.value)
+	}
+
+	positions := make([]int, len(f.keys))
+	for i := range positions {
+		positions[i] = i
+	}
+	for len(positions) >= branching {
+		var level frozenLevel
+		for j := branching - 1; j < len(positions); j += branching {
+			level.positions = append(level.positions, positions[j])
+			level.below = append(level.below, j)
+		}
+		f.levels = append(f.levels, level)
+		positions = level.positions
+	}
+
+	return f
+}
+
+// Len returns the number of elements in f.
+func (f *FrozenMap) Len() int {
+	return len(f.keys)
+}
+
+func (f *FrozenMap) keysEqual(l, r interface{}) bool {
+	if f.equal != nil {
+		return f.equal(l, r)
+	}
+	return l == r
+}
+
+// search returns the position of the first key that is greater or
+// equal to key (len(f.keys) if there is no such key).
+func (f *FrozenMap) search(key interface{}) int {
+	// predecessor is the index of the last element of the current
+	// level that is less than key, or -1 if there is no such
+	// element.
+	predecessor := -1
+	for i := len(f.levels) - 1; i >= 0; i-- {
+		level := f.levels[i]
+		for predecessor+1 < len(level.positions) && f.lessThan(f.keys[level.positions[predecessor+1]], key) {
+			predecessor++
+		}
+		if predecessor >= 0 {
+			predecessor = level.below[predecessor]
+		}
+	}
+
+	position := predecessor + 1
+	for position < len(f.keys) && f.lessThan(f.keys[position], key) {
+		position++
+	}
+	return position
+}
+
+// Get returns the value associated with key from f (nil if the key is
+// not present in f). The second return value is true when the key is
+// present.
+func (f *FrozenMap) Get(key interface{}) (value interface{}, ok bool) {
+	if i := f.search(key); i < len(f.keys) && f.keysEqual(f.keys[i], key) {
+		return f.values[i], true
+	}
+	return nil, false
+}
+
+// GetGreaterOrEqual finds the element whose key is greater than or
+// equal to min. It returns its value, its actual key, and whether such
+// an element is present in f.
+func (f *FrozenMap) GetGreaterOrEqual(min interface{}) (actualKey, value interface{}, ok bool) {
+	if i := f.search(min); i < len(f.keys) {
+		return f.keys[i], f.values[i], true
+	}
+	return nil, nil, false
+}
+
+// Iterator returns an Iterator that will go through all the elements
+// of f.
+func (f *FrozenMap) Iterator() Iterator {
+	return &frozenIter{
+		m:        f,
+		position: -1,
+		end:      len(f.keys),
+	}
+}
+
+// Seek returns a bidirectional iterator starting with the first
+// element whose key is greater or equal to key; otherwise, a nil
+// iterator is returned.
+func (f *FrozenMap) Seek(key interface{}) Iterator {
+	i := f.search(key)
+	if i == len(f.keys) {
+		return nil
+	}
+	return &frozenIter{
+		m:        f,
+		position: i,
+		end:      len(f.keys),
+	}
+}
+
+// SeekToFirst returns a bidirectional iterator starting from the first
+// element in f if f is not empty; otherwise, a nil iterator is
+// returned.
+func (f *FrozenMap) SeekToFirst() Iterator {
+	if len(f.keys) == 0 {
+		return nil
+	}
+	return &frozenIter{
+		m:        f,
+		position: 0,
+		end:      len(f.keys),
+	}
+}
+
+// SeekToLast returns a bidirectional iterator starting from the last
+// element in f if f is not empty; otherwise, a nil iterator is
+// returned.
+func (f *FrozenMap) SeekToLast() Iterator {
+	if len(f.keys) == 0 {
+		return nil
+	}
+	return &frozenIter{
+		m:        f,
+		position: len(f.keys) - 1,
+		end:      len(f.keys),
+	}
+}
+
+// Range returns an iterator that will go through all the elements of f
+// that are greater or equal than from, but less than to.
+func (f *FrozenMap) Range(from, to interface{}) Iterator {
+	start, end := f.search(from), len(f.keys)
+	if to != nil {
+		end = f.search(to)
+	}
+	return &frozenIter{
+		m:          f,
+		position:   start - 1,
+		start:      start,
+		end:        end,
+		lowerLimit: from,
+		upperLimit: to,
+	}
+}
+
+// frozenIter iterates through the elements of a FrozenMap between
+// positions start (inclusive) and end (exclusive).
+type frozenIter struct {
+	m                      *FrozenMap
+	position, start, end   int
+	lowerLimit, upperLimit interface{}
+}
+
+func (i *frozenIter) Next() bool {
+	if i.position+1 >= i.end {
+		return false
+	}
+	i.position++
+	return true
+}
+
+func (i *frozenIter) Previous() bool {
+	if i.position-1 < i.start {
+		return false
+	}
+	i.position--
+	return true
+}
+
+func (i *frozenIter) valid() bool {
+	return i.m != nil && i.position >= i.start && i.position < i.end
+}
+
+func (i *frozenIter) Key() interface{} {
+	if !i.valid() {
+		return nil
+	}
+	return i.m.keys[i.position]
+}
+
+func (i *frozenIter) Value() interface{} {
+	if !i.valid() {
+		return nil
+	}
+	return i.m.values[i.position]
+}
+
+func (i *frozenIter) Seek(key interface{}) (ok bool) {
+	if i.lowerLimit != nil && i.m.lessThan(key, i.lowerLimit) {
+		return false
+	}
+	if i.upperLimit != nil && !i.m.lessThan(key, i.upperLimit) {
+		return false
+	}
+	position := i.m.search(key)
+	if position >= i.end {
+		return false
+	}
+	i.position = position
+	return true
+}
+
+func (i *frozenIter) Close() {
+	i.m = nil
+	i.lowerLimit = nil
+	i.upperLimit = nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 1000; i++ {
+		s.Set(2*i, i)
+	}
+	f := s.Freeze()
+	s.Set(1, 1)
+	s.Delete(0)
+
+	if f.Len() != 1000 {
+		t.Errorf("Len should be 1000, not %v.", f.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		if v, ok := f.Get(2 * i); !ok || v != i {
+			t.Errorf("Get(%d) should return %d, true, not %v, %v.", 2*i, i, v, ok)
+		}
+		if v, ok := f.Get(2*i + 1); ok || v != nil {
+			t.Errorf("Get(%d) should return nil, false, not %v, %v.", 2*i+1, v, ok)
+		}
+		if key, v, ok := f.GetGreaterOrEqual(2*i - 1); !ok || key != 2*i || v != i {
+			t.Errorf("GetGreaterOrEqual(%d) should return %d, %d, true, not %v, %v, %v.", 2*i-1, 2*i, i, key, v, ok)
+		}
+	}
+	if _, _, ok := f.GetGreaterOrEqual(2000); ok {
+		t.Errorf("GetGreaterOrEqual(2000) should fail.")
+	}
+
+	empty := NewIntMap().Freeze()
+	if v, ok := empty.Get(0); ok || v != nil {
+		t.Errorf("Get on an empty map should return nil, false, not %v, %v.", v, ok)
+	}
+	if empty.Seek(0) != nil || empty.SeekToFirst() != nil || empty.SeekToLast() != nil {
+		t.Errorf("Seeking in an empty map should return nil iterators.")
+	}
+}
+
+func TestFrozenIterators(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 20; i++ {
+		s.Set(i, i)
+	}
+	f := s.Freeze()
+
+	seen := 0
+	i := f.Iterator()
+	for i.Next() {
+		if i.Key() != seen || i.Value() != seen {
+			t.Errorf("Expected %d, got %v: %v.", seen, i.Key(), i.Value())
+		}
+		seen++
+	}
+	if seen != 20 {
+		t.Errorf("Iterator went through %d elements instead of 20.", seen)
+	}
+	for i.Previous() {
+		seen--
+	}
+	if seen != 1 || i.Key() != 0 {
+		t.Errorf("Previous stopped at %v.", i.Key())
+	}
+	i.Close()
+
+	r := f.Range(5, 10)
+	seen = 0
+	for r.Next() {
+		seen++
+	}
+	if seen != 5 || r.Key() != 9 {
+		t.Errorf("Range(5, 10) went through %d elements, ending at %v.", seen, r.Key())
+	}
+	if r.Seek(4) || r.Seek(10) {
+		t.Errorf("Allowed to seek to invalid range.")
+	}
+	if !r.Seek(7) || r.Key() != 7 {
+		t.Errorf("Could not seek to 7 in Range(5, 10).")
+	}
+	for r.Previous() {
+	}
+	if r.Key() != 5 {
+		t.Errorf("Previous should stop at 5, not %v.", r.Key())
+	}
+
+	if it := f.Seek(13); it == nil || it.Key() != 13 || !it.Seek(17) || it.Key() != 17 || !it.Seek(3) || it.Key() != 3 {
+		t.Errorf("Seek doesn't work.")
+	}
+	if it := f.SeekToLast(); it.Key() != 19 || it.Next() {
+		t.Errorf("SeekToLast should stop at 19, not %v.", it.Key())
+	}
+	if it := f.SeekToFirst(); it.Key() != 0 || it.Previous() {
+		t.Errorf("SeekToFirst should stop at 0, not %v.", it.Key())
+	}
+}
+
+func BenchmarkFrozenLookup65536(b *testing.B) {
+	b.StopTimer()
+	f := makeRandomList(65536).Freeze()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		f.Get(rand.Int())
+	}
+}
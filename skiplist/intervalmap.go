@@ -0,0 +1,119 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// An Interval is the half-open range of keys [From, To), together with
+// a value.
+type Interval struct {
+	From, To interface{}
+	Value    interface{}
+}
+
+// An IntervalMap maps non-overlapping half-open ranges of keys to
+// values, for example ranges of IP addresses to their owners.
+// Adjacent intervals with equal values are coalesced, so the values
+// must be comparable with ==. An interval whose To is nil has no end:
+// it contains all the keys from its From on.
+type IntervalMap struct {
+	// list maps the start of every interval to an *Interval.
+	list *SkipList
+}
+
+// NewIntervalMap returns a new IntervalMap that will use lessThan as
+// the comparison function for keys.
+func NewIntervalMap(lessThan func(l, r interface{}) bool) *IntervalMap {
+	return &IntervalMap{list: NewCustomMap(lessThan)}
+}
+
+// Len returns the number of intervals stored in m.
+func (m *IntervalMap) Len() int {
+	return m.list.Len()
+}
+
+// startingBefore returns the node of the last interval starting
+// before key, or nil if there is no such interval.
+func (m *IntervalMap) startingBefore(key interface{}) *node {
+	return m.list.lastBefore(m.list.getPath(m.list.header, nil, key))
+}
+
+// Lookup returns the value of the interval containing point. The
+// second return value is true if there is such an interval.
+func (m *IntervalMap) Lookup(point interface{}) (value interface{}, ok bool) {
+	if _, i, ok := m.list.GetLessOrEqual(point); ok {
+		if interval := i.(*Interval); m.list.before(point, interval.To) {
+			return interval.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Clear removes the range [from, to) from m, trimming or splitting the
+// intervals that overlap it. If to is nil, it removes everything from
+// from on.
+func (m *IntervalMap) Clear(from, to interface{}) {
+	s := m.list
+	if from == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	if to != nil && !s.lessThan(from, to) {
+		return
+	}
+
+	// Split the interval containing to, if it starts before to.
+	if to != nil {
+		if n := m.startingBefore(to); n != nil {
+			if interval := n.value.(*Interval); s.before(to, interval.To) {
+				s.Set(to, &Interval{to, interval.To, interval.Value})
+				interval.To = to
+			}
+		}
+	}
+	// Trim the interval containing from, if it starts before from.
+	if n := m.startingBefore(from); n != nil {
+		if interval := n.value.(*Interval); s.before(from, interval.To) {
+			interval.To = from
+		}
+	}
+	s.deleteRange(from, to)
+}
+
+// Assign maps the range [from, to) to value, replacing the parts of
+// the existing intervals that overlap it. If to is nil, the range has
+// no end. The new interval is merged with its neighbors if they are
+// adjacent and have the same value.
+func (m *IntervalMap) Assign(from, to, value interface{}) {
+	s := m.list
+	m.Clear(from, to)
+	if to != nil && !s.lessThan(from, to) {
+		return
+	}
+
+	var interval *Interval
+	if n := m.startingBefore(from); n != nil && s.keysEqual(n.value.(*Interval).To, from) && n.value.(*Interval).Value == value {
+		interval = n.value.(*Interval)
+		interval.To = to
+	} else {
+		interval = &Interval{from, to, value}
+		s.Set(from, interval)
+	}
+	if to == nil {
+		return
+	}
+	if next, ok := s.Get(to); ok && next.(*Interval).Value == value {
+		interval.To = next.(*Interval).To
+		s.Delete(to)
+	}
+}
+
+// unwrapInterval returns a copy of the *Interval stored as a value.
+func unwrapInterval(value interface{}) interface{} {
+	return *value.(*Interval)
+}
+
+// Iterator returns an Iterator that will go through all the intervals
+// of m, in order. Its keys are the starts of the intervals, and its
+// values are Intervals.
+func (m *IntervalMap) Iterator() Iterator {
+	return unwrapped(m.list.Iterator(), unwrapInterval)
+}
@@ -0,0 +1,114 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func (m *IntervalMap) intervals() (intervals []Interval) {
+	for i := m.Iterator(); i.Next(); {
+		intervals = append(intervals, i.Value().(Interval))
+	}
+	return intervals
+}
+
+func TestIntervalMap(t *testing.T) {
+	m := NewIntervalMap(intLessThan)
+	m.Assign(0, 10, "a")
+	m.Assign(20, 30, "b")
+	m.Assign(5, 25, "c")
+	expected := []Interval{{0, 5, "a"}, {5, 25, "c"}, {25, 30, "b"}}
+	if intervals := m.intervals(); !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("Intervals should be %v, not %v.", expected, intervals)
+	}
+
+	m.Clear(10, 15)
+	expected = []Interval{{0, 5, "a"}, {5, 10, "c"}, {15, 25, "c"}, {25, 30, "b"}}
+	if intervals := m.intervals(); !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("Intervals should be %v, not %v.", expected, intervals)
+	}
+
+	m.Assign(10, 15, "c")
+	m.Assign(30, 40, "b")
+	expected = []Interval{{0, 5, "a"}, {5, 25, "c"}, {25, 40, "b"}}
+	if intervals := m.intervals(); !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("Intervals should be %v, not %v.", expected, intervals)
+	}
+
+	for point, value := range map[int]interface{}{-1: nil, 0: "a", 4: "a", 5: "c", 24: "c", 39: "b", 40: nil} {
+		if got, ok := m.Lookup(point); got != value || ok != (value != nil) {
+			t.Errorf("Lookup(%v) should return %v, not %v, %v.", point, value, got, ok)
+		}
+	}
+}
+
+func TestIntervalMapUnbounded(t *testing.T) {
+	m := NewIntervalMap(intLessThan)
+	m.Assign(0, 10, "a")
+	m.Assign(20, 30, "b")
+	m.Assign(25, nil, "c")
+	expected := []Interval{{0, 10, "a"}, {20, 25, "b"}, {25, nil, "c"}}
+	if intervals := m.intervals(); !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("Intervals should be %v, not %v.", expected, intervals)
+	}
+	if value, ok := m.Lookup(1000000); !ok || value != "c" {
+		t.Errorf("Lookup(1000000) should be c, not %v.", value)
+	}
+
+	m.Assign(40, 50, "d")
+	m.Assign(5, nil, "a")
+	expected = []Interval{{0, nil, "a"}}
+	if intervals := m.intervals(); !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("Intervals should be %v, not %v.", expected, intervals)
+	}
+
+	m.Clear(10, 20)
+	m.Clear(30, nil)
+	expected = []Interval{{0, 10, "a"}, {20, 30, "a"}}
+	if intervals := m.intervals(); !reflect.DeepEqual(intervals, expected) {
+		t.Errorf("Intervals should be %v, not %v.", expected, intervals)
+	}
+	if _, ok := m.Lookup(30); ok {
+		t.Errorf("30 should not be in any interval.")
+	}
+}
+
+func TestIntervalMapRandom(t *testing.T) {
+	const size = 100
+	m := NewIntervalMap(intLessThan)
+	var expected [size]interface{}
+	for n := 0; n < 1000; n++ {
+		from, to := rand.Intn(size), rand.Intn(size)
+		if rand.Intn(4) == 0 {
+			m.Clear(from, to)
+			for i := from; i < to; i++ {
+				expected[i] = nil
+			}
+		} else {
+			value := rand.Intn(3)
+			m.Assign(from, to, value)
+			for i := from; i < to; i++ {
+				expected[i] = value
+			}
+		}
+
+		for point := range expected {
+			if value, _ := m.Lookup(point); value != expected[point] {
+				t.Fatalf("Lookup(%v) should return %v, not %v.", point, expected[point], value)
+			}
+		}
+		intervals := m.intervals()
+		for i, interval := range intervals {
+			if !intLessThan(interval.From, interval.To) {
+				t.Fatalf("Interval %v is empty.", interval)
+			}
+			if i > 0 && intervals[i-1].To == interval.From && intervals[i-1].Value == interval.Value {
+				t.Fatalf("Intervals %v and %v should have been merged.", intervals[i-1], interval)
+			}
+		}
+	}
+}
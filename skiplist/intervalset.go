@@ -0,0 +1,194 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// An IntervalSet holds half-open intervals that may overlap, and finds
+// the intervals containing a point or overlapping a range in
+// O(log n + k) expected time, where k is the number of intervals
+// returned. It is an AggregateSkipList ordered by the starts of the
+// intervals, where every link knows the maximum end of the intervals
+// it skips over, so that queries can skip the parts of the list that
+// end too early.
+type IntervalSet struct {
+	lessThan  func(l, r interface{}) bool
+	intervals *AggregateSkipList
+	// sequence distinguishes intervals with the same bounds.
+	sequence uint64
+}
+
+// intervalKey is the key of an interval in an IntervalSet. The value
+// associated with it is the end of the interval.
+type intervalKey struct {
+	from, to interface{}
+	value    interface{}
+	sequence uint64
+}
+
+// NewIntervalSet returns a new IntervalSet that will use lessThan as
+// the comparison function for the bounds of the intervals.
+func NewIntervalSet(lessThan func(l, r interface{}) bool) *IntervalSet {
+	s := &IntervalSet{lessThan: lessThan}
+	maxEnd := Monoid{
+		Identity: nil,
+		Combine: func(l, r interface{}) interface{} {
+			if l == nil || r != nil && lessThan(l, r) {
+				return r
+			}
+			return l
+		},
+	}
+	s.intervals = NewAggregateSkipList(s.keyLessThan, maxEnd)
+	s.intervals.list.equal = func(l, r interface{}) bool {
+		return l.(*intervalKey).sequence == r.(*intervalKey).sequence
+	}
+	return s
+}
+
+// keyLessThan orders intervals by their starts, then by their ends,
+// and then by the order in which they were added.
+func (s *IntervalSet) keyLessThan(l, r interface{}) bool {
+	a, b := l.(*intervalKey), r.(*intervalKey)
+	if s.lessThan(a.from, b.from) {
+		return true
+	}
+	if s.lessThan(b.from, a.from) {
+		return false
+	}
+	// A nil end is used to seek to the first interval with a
+	// given start.
+	if a.to == nil || b.to == nil {
+		return a.to == nil && b.to != nil
+	}
+	if s.lessThan(a.to, b.to) {
+		return true
+	}
+	if s.lessThan(b.to, a.to) {
+		return false
+	}
+	return a.sequence < b.sequence
+}
+
+// Len returns the number of intervals in s.
+func (s *IntervalSet) Len() int {
+	return s.intervals.Len()
+}
+
+// Add adds the interval [from, to) with the given value to s. Empty
+// intervals (when to is not greater than from) are ignored.
+func (s *IntervalSet) Add(from, to, value interface{}) {
+	if from == nil || to == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	if !s.lessThan(from, to) {
+		return
+	}
+	s.sequence++
+	s.intervals.Set(&intervalKey{from, to, value, s.sequence}, to)
+}
+
+// Remove removes one interval [from, to) with the given value from s.
+// It returns false if there is no such interval. The values must be
+// comparable with ==.
+func (s *IntervalSet) Remove(from, to, value interface{}) (ok bool) {
+	if from == nil || to == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	start := &intervalKey{from: from, to: to}
+	for i := s.intervals.list.Seek(start); i != nil; {
+		key := i.Key().(*intervalKey)
+		if s.lessThan(from, key.from) || s.lessThan(to, key.to) {
+			return false
+		}
+		if key.value == value {
+			s.intervals.Delete(key)
+			return true
+		}
+		if !i.Next() {
+			break
+		}
+	}
+	return false
+}
+
+// Stabbing returns the intervals of s that contain point, ordered by
+// their starts.
+func (s *IntervalSet) Stabbing(point interface{}) []Interval {
+	return s.query(point, func(from interface{}) bool {
+		return !s.lessThan(point, from)
+	})
+}
+
+// Overlapping returns the intervals of s that overlap [from, to),
+// ordered by their starts. If [from, to) is empty, it overlaps
+// nothing, and Overlapping returns nil.
+func (s *IntervalSet) Overlapping(from, to interface{}) []Interval {
+	if !s.lessThan(from, to) {
+		return nil
+	}
+	return s.query(from, func(start interface{}) bool {
+		return s.lessThan(start, to)
+	})
+}
+
+// query returns the intervals ending after low, among those whose
+// starts satisfy startOK. startOK must hold for a prefix of the
+// intervals.
+func (s *IntervalSet) query(low interface{}, startOK func(from interface{}) bool) (intervals []Interval) {
+	a := s.intervals
+	current := a.list.header.next()
+	for current != nil {
+		key := current.key.(*intervalKey)
+		if !startOK(key.from) {
+			break
+		}
+
+		// Skip the longest span of intervals that all end too
+		// early.
+		links := a.entry(current).links
+		level := len(links) - 1
+		for level >= 0 && s.lessThan(low, links[level]) {
+			level--
+		}
+		if level >= 0 {
+			current = current.forward[level]
+			continue
+		}
+
+		intervals = append(intervals, Interval{key.from, key.to, key.value})
+		current = current.next()
+	}
+	return intervals
+}
+
+// Iterator returns an Iterator that will go through all the intervals
+// of s, ordered by their starts. Its values are Intervals.
+func (s *IntervalSet) Iterator() Iterator {
+	return &intervalSetIter{s.intervals.list.Iterator()}
+}
+
+// intervalSetIter iterates over the keys of the underlying skip list
+// of an IntervalSet, returning the starts of the intervals as keys and
+// the intervals as values.
+type intervalSetIter struct {
+	Iterator
+}
+
+func (i *intervalSetIter) Key() interface{} {
+	if key := i.Iterator.Key(); key != nil {
+		return key.(*intervalKey).from
+	}
+	return nil
+}
+
+func (i *intervalSetIter) Value() interface{} {
+	if key := i.Iterator.Key(); key != nil {
+		key := key.(*intervalKey)
+		return Interval{key.from, key.to, key.value}
+	}
+	return nil
+}
+
+func (i *intervalSetIter) Seek(key interface{}) (ok bool) {
+	return i.Iterator.Seek(&intervalKey{from: key})
+}
@@ -0,0 +1,106 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestIntervalSet(t *testing.T) {
+	s := NewIntervalSet(intLessThan)
+	s.Add(0, 10, "a")
+	s.Add(5, 7, "b")
+	s.Add(5, 7, "c")
+	s.Add(8, 20, "d")
+	s.Add(30, 30, "empty")
+	if s.Len() != 4 {
+		t.Errorf("Len should be 4, not %v.", s.Len())
+	}
+
+	expected := []Interval{{0, 10, "a"}, {5, 7, "b"}, {5, 7, "c"}}
+	if stabbing := s.Stabbing(6); !reflect.DeepEqual(stabbing, expected) {
+		t.Errorf("Stabbing(6) should be %v, not %v.", expected, stabbing)
+	}
+	if stabbing := s.Stabbing(20); stabbing != nil {
+		t.Errorf("Stabbing(20) should be empty, not %v.", stabbing)
+	}
+	expected = []Interval{{0, 10, "a"}, {8, 20, "d"}}
+	if overlapping := s.Overlapping(7, 9); !reflect.DeepEqual(overlapping, expected) {
+		t.Errorf("Overlapping(7, 9) should be %v, not %v.", expected, overlapping)
+	}
+	if overlapping := s.Overlapping(6, 6); overlapping != nil {
+		t.Errorf("Overlapping(6, 6) should be empty, not %v.", overlapping)
+	}
+	if overlapping := s.Overlapping(9, 6); overlapping != nil {
+		t.Errorf("Overlapping(9, 6) should be empty, not %v.", overlapping)
+	}
+
+	if !s.Remove(5, 7, "c") {
+		t.Errorf("Remove(5, 7, c) should have succeeded.")
+	}
+	if s.Remove(5, 7, "c") || s.Remove(5, 8, "b") {
+		t.Errorf("Remove of a missing interval should fail.")
+	}
+	expected = []Interval{{0, 10, "a"}, {5, 7, "b"}}
+	if stabbing := s.Stabbing(6); !reflect.DeepEqual(stabbing, expected) {
+		t.Errorf("Stabbing(6) should be %v, not %v.", expected, stabbing)
+	}
+
+	i := s.Iterator()
+	if !i.Seek(5) || i.Key() != 5 || i.Value() != (Interval{5, 7, "b"}) {
+		t.Errorf("Seek(5) should find [5, 7), not %v.", i.Value())
+	}
+}
+
+func TestIntervalSetRandom(t *testing.T) {
+	s := NewIntervalSet(intLessThan)
+	var all []Interval
+	for n := 0; n < 2000; n++ {
+		if len(all) > 0 && rand.Intn(3) == 0 {
+			j := rand.Intn(len(all))
+			if !s.Remove(all[j].From, all[j].To, all[j].Value) {
+				t.Fatalf("Remove(%v) failed.", all[j])
+			}
+			all = append(all[:j], all[j+1:]...)
+		} else {
+			from := rand.Intn(1000)
+			interval := Interval{from, from + 1 + rand.Intn(50), n}
+			s.Add(interval.From, interval.To, interval.Value)
+			all = append(all, interval)
+		}
+		if err := s.intervals.list.Verify(); err != nil {
+			t.Fatalf("Verify failed: %v.", err)
+		}
+
+		from := rand.Intn(1100)
+		to := from + rand.Intn(20)
+		count := 0
+		for _, interval := range all {
+			if from < to && interval.From.(int) < to && interval.To.(int) > from {
+				count++
+			}
+		}
+		overlapping := s.Overlapping(from, to)
+		if len(overlapping) != count {
+			t.Fatalf("Overlapping(%v, %v) should return %v intervals, not %v.", from, to, count, len(overlapping))
+		}
+		for _, interval := range overlapping {
+			if !(interval.From.(int) < to && interval.To.(int) > from) {
+				t.Fatalf("Interval %v doesn't overlap [%v, %v).", interval, from, to)
+			}
+		}
+
+		count = 0
+		for _, interval := range all {
+			if interval.From.(int) <= from && from < interval.To.(int) {
+				count++
+			}
+		}
+		if stabbing := s.Stabbing(from); len(stabbing) != count {
+			t.Fatalf("Stabbing(%v) should return %v intervals, not %v.", from, count, len(stabbing))
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package lsm
+
+import (
+	"github.com/ryszard/goskiplist/skiplist"
+)
+
+// A source is a memtable or a table, seen as a sorted sequence of
+// entries, tombstones included.
+type source interface {
+	// seek returns an iterator positioned at the first entry whose
+	// key is greater or equal to key (the first entry if key is
+	// nil), or nil if there is no such entry.
+	seek(key interface{}) entryIterator
+	// last returns an iterator positioned at the last entry, or nil
+	// if there are no entries.
+	last() entryIterator
+}
+
+// An entryIterator is an iterator over the entries of a source. Kind
+// tells whether the current entry is a tombstone.
+type entryIterator interface {
+	skiplist.Iterator
+	Kind() skiplist.EntryKind
+}
+
+type memtableSource struct {
+	*skiplist.SkipList
+}
+
+func (m memtableSource) seek(key interface{}) entryIterator {
+	i := m.RawIterator()
+	if key == nil && i.SeekToFirst() || key != nil && i.Seek(key) {
+		return i
+	}
+	return nil
+}
+
+func (m memtableSource) last() entryIterator {
+	if i := m.RawIterator(); i.SeekToLast() {
+		return i
+	}
+	return nil
+}
+
+type tableSource struct {
+	*skiplist.Table
+}
+
+func (t tableSource) seek(key interface{}) entryIterator {
+	var i *skiplist.TableIterator
+	if key == nil {
+		i = t.SeekToFirst()
+	} else {
+		i = t.Seek(key)
+	}
+	if i == nil {
+		return nil
+	}
+	return i
+}
+
+func (t tableSource) last() entryIterator {
+	if i := t.SeekToLast(); i != nil {
+		return i
+	}
+	return nil
+}
+
+// The positions of a cursor, or of an Iterator.
+const (
+	before = iota
+	at
+	after
+)
+
+// A cursor tracks the position of an iterator over a source. The
+// iterators of the skiplist package stay on the first or the last
+// element when they can't move, so the cursor remembers whether it
+// went past them.
+type cursor struct {
+	source source
+	// it is nil if the source has no entries after the position
+	// of the last seek.
+	it    entryIterator
+	state int
+}
+
+func (c *cursor) seek(key interface{}) {
+	c.it = c.source.seek(key)
+	c.state = at
+	if c.it == nil {
+		c.state = after
+	}
+}
+
+func (c *cursor) next() {
+	switch c.state {
+	case before:
+		c.state = at
+		if c.it == nil {
+			c.state = after
+		}
+	case at:
+		if !c.it.Next() {
+			c.state = after
+		}
+	}
+}
+
+func (c *cursor) previous() {
+	switch c.state {
+	case after:
+		if c.it == nil {
+			if c.it = c.source.last(); c.it == nil {
+				c.state = before
+				return
+			}
+		}
+		c.state = at
+	case at:
+		if !c.it.Previous() {
+			c.state = before
+		}
+	}
+}
+
+func (c *cursor) key() interface{} {
+	return c.it.Key()
+}
+
+func (c *cursor) err() error {
+	if i, ok := c.it.(interface {
+		Err() error
+	}); ok {
+		return i.Err()
+	}
+	return nil
+}
+
+// An Iterator goes through the entries of several sources, merged in
+// key order. When a key is present in several sources, the newest
+// entry shadows the others, and keys whose newest entry is a tombstone
+// are skipped. It implements skiplist.Iterator.
+type Iterator struct {
+	lessThan func(l, r interface{}) bool
+	// cursors are ordered from the newest source to the oldest.
+	cursors    []*cursor
+	from, to   interface{}
+	state      int
+	key, value interface{}
+	// deleted is set if the current entry is a tombstone.
+	deleted bool
+	err     error
+}
+
+func (s *Store) newIterator(sources []source, from, to interface{}) *Iterator {
+	i := &Iterator{
+		lessThan: s.opts.LessThan,
+		from:     from,
+		to:       to,
+	}
+	for _, source := range sources {
+		i.cursors = append(i.cursors, &cursor{source: source})
+	}
+	i.reset(from)
+	return i
+}
+
+// reset positions the iterator before the first entry whose key is
+// greater or equal to key.
+func (i *Iterator) reset(key interface{}) {
+	for _, c := range i.cursors {
+		c.seek(key)
+	}
+	i.state = before
+}
+
+// checkErrors stops the iteration if reading a source failed.
+func (i *Iterator) checkErrors() bool {
+	for _, c := range i.cursors {
+		if c.state == at {
+			if err := c.err(); err != nil {
+				i.err = err
+				i.state = after
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Next moves to the next entry. It returns false if there is none, or
+// if reading a source failed (see Err).
+func (i *Iterator) Next() bool {
+	return i.next(false)
+}
+
+func (i *Iterator) next(tombstones bool) bool {
+	// Like the iterators of the skiplist package, stay on the
+	// current entry if there is no next one.
+	state, key, value, deleted := i.state, i.key, i.value, i.deleted
+	for i.state != after {
+		for _, c := range i.cursors {
+			for c.state == before || c.state == at && i.behind(c) {
+				c.next()
+			}
+		}
+		if !i.checkErrors() {
+			return false
+		}
+
+		var best *cursor
+		for _, c := range i.cursors {
+			if c.state == at && (best == nil || i.lessThan(c.key(), best.key())) {
+				best = c
+			}
+		}
+		if best == nil || i.to != nil && !i.lessThan(best.key(), i.to) {
+			i.state, i.key, i.value, i.deleted = state, key, value, deleted
+			return false
+		}
+		i.state, i.key, i.value = at, best.key(), best.it.Value()
+		if i.deleted = best.it.Kind() == skiplist.TombstoneEntry; !i.deleted || tombstones {
+			return true
+		}
+	}
+	return false
+}
+
+// behind returns true if c is at or before the current position of i
+// (or before from, if i is before its first entry).
+func (i *Iterator) behind(c *cursor) bool {
+	if i.state == before {
+		return i.from != nil && i.lessThan(c.key(), i.from)
+	}
+	return !i.lessThan(i.key, c.key())
+}
+
+// ahead returns true if c is at or after the current position of i (or
+// after to, if i is after its last entry).
+func (i *Iterator) ahead(c *cursor) bool {
+	if i.state == after {
+		return i.to != nil && !i.lessThan(c.key(), i.to)
+	}
+	return !i.lessThan(c.key(), i.key)
+}
+
+// Previous moves to the previous entry. It returns false if there is
+// none, or if reading a source failed (see Err).
+func (i *Iterator) Previous() bool {
+	state, key, value, deleted := i.state, i.key, i.value, i.deleted
+	for i.state != before && i.err == nil {
+		for _, c := range i.cursors {
+			for c.state == after || c.state == at && i.ahead(c) {
+				c.previous()
+			}
+		}
+		if !i.checkErrors() {
+			return false
+		}
+
+		var best *cursor
+		for _, c := range i.cursors {
+			if c.state == at && (best == nil || i.lessThan(best.key(), c.key())) {
+				best = c
+			}
+		}
+		if best == nil || i.from != nil && i.lessThan(best.key(), i.from) {
+			i.state, i.key, i.value, i.deleted = state, key, value, deleted
+			return false
+		}
+		i.state, i.key, i.value = at, best.key(), best.it.Value()
+		if i.deleted = best.it.Kind() == skiplist.TombstoneEntry; !i.deleted {
+			return true
+		}
+	}
+	return false
+}
+
+// Key returns the current key.
+func (i *Iterator) Key() interface{} {
+	if i.state != at {
+		return nil
+	}
+	return i.key
+}
+
+// Value returns the current value.
+func (i *Iterator) Value() interface{} {
+	if i.state != at {
+		return nil
+	}
+	return i.value
+}
+
+// Seek moves to the first entry whose key is greater or equal to key.
+// It returns false if there is no such entry within the range of the
+// iterator; the iterator is then exhausted.
+func (i *Iterator) Seek(key interface{}) (ok bool) {
+	if i.from != nil && i.lessThan(key, i.from) || i.to != nil && !i.lessThan(key, i.to) {
+		return false
+	}
+	i.reset(key)
+	return i.Next()
+}
+
+// Close releases the resources associated with the iterator.
+func (i *Iterator) Close() {
+	i.cursors = nil
+	i.key, i.value = nil, nil
+	i.state = after
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (i *Iterator) Err() error {
+	return i.err
+}
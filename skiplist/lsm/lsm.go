@@ -0,0 +1,444 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+// Package lsm implements a small embedded ordered key-value store,
+// organized as a log-structured merge tree.
+//
+// Writes go to an in-memory skip list, the memtable. When it is full,
+// it becomes immutable and a new memtable takes its place; immutable
+// memtables are then flushed to sorted table files. Deletions are
+// recorded as tombstones, which shadow the older values of the key
+// until compaction drops them. Tables of similar sizes are merged
+// together (size-tiered compaction), so that lookups only have to
+// check a logarithmic number of tables.
+//
+// The memtables are not logged, so writes that weren't flushed (by
+// Flush, Close or because the memtable became full) are lost if the
+// process crashes.
+package lsm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryszard/goskiplist/skiplist"
+)
+
+const (
+	manifestName = "MANIFEST"
+	tableSuffix  = ".sst"
+)
+
+// Options configure a Store. LessThan, Keys and Values are required.
+type Options struct {
+	// LessThan is the comparison function for keys.
+	LessThan func(l, r interface{}) bool
+	// Keys and Values serialize keys and values in table files.
+	Keys, Values skiplist.Codec
+	// MemtableSize is the number of writes (sets and deletes) to a
+	// memtable before it becomes immutable. The default is 4096.
+	MemtableSize int
+	// MaxImmutable is the number of immutable memtables that are
+	// kept in memory before they are flushed. The default is 1.
+	MaxImmutable int
+	// CompactionThreshold is the number of tables of similar sizes
+	// that are merged together. The default is 4.
+	CompactionThreshold int
+}
+
+// ErrBadManifest is returned by Open when the manifest can't be
+// parsed.
+var ErrBadManifest = errors.New("lsm: bad manifest")
+
+// A Store is an ordered key-value store kept in a directory. It is not
+// safe for concurrent use.
+type Store struct {
+	dir  string
+	opts Options
+	// memtable receives the writes, and writes is their number.
+	memtable *skiplist.SkipList
+	writes   int
+	// immutable holds the memtables waiting to be flushed, the
+	// newest first.
+	immutable []*skiplist.SkipList
+	// tables holds the table files, the newest first.
+	tables []*table
+	// nextFile is the number of the next table file.
+	nextFile int
+}
+
+type table struct {
+	*skiplist.Table
+	file *os.File
+	name string
+}
+
+// Open opens the store kept in directory dir, creating the directory
+// if necessary.
+func Open(dir string, opts Options) (*Store, error) {
+	if opts.MemtableSize <= 0 {
+		opts.MemtableSize = 4096
+	}
+	if opts.MaxImmutable <= 0 {
+		opts.MaxImmutable = 1
+	}
+	if opts.CompactionThreshold < 2 {
+		opts.CompactionThreshold = 4
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:  dir,
+		opts: opts,
+	}
+	s.memtable = s.newMemtable()
+	names, err := s.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		t, err := s.openTable(name)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.tables = append(s.tables, t)
+	}
+
+	// Remove the tables that were written, but not added to the
+	// manifest, before a crash.
+	listed := make(map[string]bool)
+	for _, name := range names {
+		listed[name] = true
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), tableSuffix) && !listed[f.Name()] {
+			os.Remove(filepath.Join(dir, f.Name()))
+		}
+	}
+	return s, nil
+}
+
+// readManifest returns the names of the tables listed in the manifest,
+// the newest first, and sets nextFile.
+func (s *Store) readManifest() (names []string, err error) {
+	f, err := os.Open(filepath.Join(s.dir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return nil, ErrBadManifest
+	}
+	if _, err := fmt.Sscanf(scanner.Text(), "next %d", &s.nextFile); err != nil {
+		return nil, ErrBadManifest
+	}
+	for scanner.Scan() {
+		names = append(names, scanner.Text())
+	}
+	return names, scanner.Err()
+}
+
+// writeManifest atomically replaces the manifest with one listing the
+// current tables.
+func (s *Store) writeManifest() error {
+	path := filepath.Join(s.dir, manifestName)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "next %d\n", s.nextFile)
+	for _, t := range s.tables {
+		fmt.Fprintf(w, "%s\n", t.name)
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *Store) openTable(name string) (*table, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t, err := skiplist.OpenTable(f, info.Size(), s.opts.LessThan, s.opts.Keys, s.opts.Values)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &table{t, f, name}, nil
+}
+
+// writeTable writes the elements of i, dropping tombstones if
+// dropTombstones is set, to a new table file and opens it.
+func (s *Store) writeTable(i *Iterator, dropTombstones bool) (*table, error) {
+	name := fmt.Sprintf("%06d%s", s.nextFile, tableSuffix)
+	s.nextFile++
+	f, err := os.Create(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	w := skiplist.NewTableWriter(f, s.opts.Keys, s.opts.Values)
+	for i.next(!dropTombstones) {
+		if i.deleted {
+			err = w.AddTombstone(i.Key())
+		} else {
+			err = w.Add(i.Key(), i.Value())
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = i.Err()
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return s.openTable(name)
+}
+
+// Get returns the value associated with key (nil if the key is not
+// present). The second return value is true when the key is present.
+// err is set if reading a table failed.
+func (s *Store) Get(key interface{}) (value interface{}, ok bool, err error) {
+	for _, m := range append([]*skiplist.SkipList{s.memtable}, s.immutable...) {
+		if value, ok := m.Get(key); ok {
+			return value, true, nil
+		}
+		if m.Deleted(key) {
+			return nil, false, nil
+		}
+	}
+	// The store doesn't write range tombstones, so the entry of
+	// the key is enough to tell whether a table shadows it.
+	for _, t := range s.tables {
+		i := t.Seek(key)
+		if i == nil {
+			continue
+		}
+		if err := i.Err(); err != nil {
+			return nil, false, err
+		}
+		if s.opts.LessThan(key, i.Key()) {
+			continue
+		}
+		if i.Kind() == skiplist.TombstoneEntry {
+			return nil, false, nil
+		}
+		return i.Value(), true, nil
+	}
+	return nil, false, nil
+}
+
+// Set sets the value associated with key.
+func (s *Store) Set(key, value interface{}) error {
+	s.memtable.Set(key, value)
+	s.writes++
+	return s.rotate(false)
+}
+
+// Delete removes key, by recording a tombstone.
+func (s *Store) Delete(key interface{}) error {
+	s.memtable.Delete(key)
+	s.writes++
+	return s.rotate(false)
+}
+
+// newMemtable returns an empty memtable, which records tombstones.
+func (s *Store) newMemtable() *skiplist.SkipList {
+	m := skiplist.NewCustomMap(s.opts.LessThan)
+	m.EnableTombstones()
+	return m
+}
+
+// rotate makes the memtable immutable if it is full (or if force is
+// set and it isn't empty), and flushes the immutable memtables if
+// there are too many of them.
+func (s *Store) rotate(force bool) error {
+	if s.writes >= s.opts.MemtableSize || force && s.writes > 0 {
+		s.immutable = append([]*skiplist.SkipList{s.memtable}, s.immutable...)
+		s.memtable, s.writes = s.newMemtable(), 0
+	}
+	if len(s.immutable) > s.opts.MaxImmutable || force {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush writes the immutable memtables to tables, the oldest first.
+// The tables are compacted after every flush, which keeps the newer
+// tables in the lower tiers.
+func (s *Store) flush() error {
+	for len(s.immutable) > 0 {
+		oldest := s.immutable[len(s.immutable)-1]
+		t, err := s.writeTable(s.newIterator([]source{memtableSource{oldest}}, nil, nil), false)
+		if err != nil {
+			return err
+		}
+		s.tables = append([]*table{t}, s.tables...)
+		if err := s.writeManifest(); err != nil {
+			s.tables = s.tables[1:]
+			t.file.Close()
+			os.Remove(t.file.Name())
+			return err
+		}
+		s.immutable = s.immutable[:len(s.immutable)-1]
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tier returns the size tier of a table with the given number of
+// entries: tables in tier k have between MemtableSize·threshold^k and
+// MemtableSize·threshold^(k+1) entries.
+func (s *Store) tier(entries int) (tier int) {
+	for size := s.opts.MemtableSize * s.opts.CompactionThreshold; entries >= size; size *= s.opts.CompactionThreshold {
+		tier++
+	}
+	return tier
+}
+
+// compact merges runs of CompactionThreshold consecutive tables in the
+// same tier, until there are none left. Only consecutive tables are
+// merged, so that the merged table can take their place in the order
+// of the tables.
+func (s *Store) compact() error {
+	threshold := s.opts.CompactionThreshold
+	for {
+		start := -1
+		for i := 0; i+threshold <= len(s.tables) && start < 0; i++ {
+			start = i
+			for _, t := range s.tables[i+1 : i+threshold] {
+				if s.tier(t.Len()) != s.tier(s.tables[i].Len()) {
+					start = -1
+					break
+				}
+			}
+		}
+		if start < 0 {
+			return nil
+		}
+		if err := s.merge(start, start+threshold); err != nil {
+			return err
+		}
+	}
+}
+
+// merge replaces the tables from start to end (exclusive) by a single
+// table. Tombstones are dropped if no older table remains.
+func (s *Store) merge(start, end int) error {
+	var sources []source
+	for _, t := range s.tables[start:end] {
+		sources = append(sources, tableSource{t.Table})
+	}
+	merged, err := s.writeTable(s.newIterator(sources, nil, nil), end == len(s.tables))
+	if err != nil {
+		return err
+	}
+
+	old := append([]*table(nil), s.tables[start:end]...)
+	tables := append(append(append([]*table(nil), s.tables[:start]...), merged), s.tables[end:]...)
+	previous := s.tables
+	s.tables = tables
+	if err := s.writeManifest(); err != nil {
+		s.tables = previous
+		merged.file.Close()
+		os.Remove(merged.file.Name())
+		return err
+	}
+	for _, t := range old {
+		t.file.Close()
+		os.Remove(t.file.Name())
+	}
+	return nil
+}
+
+// Flush writes all the entries held in memory to tables.
+func (s *Store) Flush() error {
+	return s.rotate(true)
+}
+
+// Close flushes s and closes its files. s must not be used afterwards.
+func (s *Store) Close() (err error) {
+	if s.memtable != nil {
+		err = s.Flush()
+	}
+	for _, t := range s.tables {
+		if closeErr := t.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	s.memtable, s.immutable, s.tables = nil, nil, nil
+	return err
+}
+
+// sources returns the sources of s, the newest first.
+func (s *Store) sources() (sources []source) {
+	sources = append(sources, memtableSource{s.memtable})
+	for _, m := range s.immutable {
+		sources = append(sources, memtableSource{m})
+	}
+	for _, t := range s.tables {
+		sources = append(sources, tableSource{t.Table})
+	}
+	return sources
+}
+
+// Range returns an iterator that will go through all the elements of
+// s that are greater or equal than from, but less than to. A nil from
+// stands for the first key, and a nil to for the end of s. s must not
+// be modified while the iterator is in use.
+func (s *Store) Range(from, to interface{}) *Iterator {
+	return s.newIterator(s.sources(), from, to)
+}
+
+// Iterator returns an iterator that will go through all the elements
+// of s.
+func (s *Store) Iterator() *Iterator {
+	return s.Range(nil, nil)
+}
@@ -0,0 +1,226 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package lsm
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryszard/goskiplist/skiplist"
+)
+
+var _ skiplist.Iterator = (*Iterator)(nil)
+
+// intCodec is a Codec for ints.
+type intCodec struct{}
+
+func (intCodec) Encode(v interface{}) ([]byte, error) {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutVarint(b, int64(v.(int)))], nil
+}
+
+func (intCodec) Decode(data []byte) (interface{}, error) {
+	x, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, skiplist.ErrCorrupt
+	}
+	return int(x), nil
+}
+
+func intLessThan(l, r interface{}) bool {
+	return l.(int) < r.(int)
+}
+
+func openStore(t *testing.T, dir string) *Store {
+	s, err := Open(dir, Options{
+		LessThan:            intLessThan,
+		Keys:                intCodec{},
+		Values:              intCodec{},
+		MemtableSize:        16,
+		CompactionThreshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v.", err)
+	}
+	return s
+}
+
+// check compares the contents of s with expected, for keys in [0,
+// size).
+func check(t *testing.T, s *Store, expected map[int]int, size int) {
+	for key := 0; key < size; key++ {
+		value, ok, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%v) failed: %v.", key, err)
+		}
+		if want, present := expected[key]; ok != present || ok && value != want {
+			t.Fatalf("Get(%v) should return %v, %v, not %v, %v.", key, want, present, value, ok)
+		}
+	}
+
+	from, to := rand.Intn(size), rand.Intn(size)
+	var keys []int
+	for key := from; key < to; key++ {
+		if _, ok := expected[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	i := s.Range(from, to)
+	for _, key := range keys {
+		if !i.Next() || i.Key() != key || i.Value() != expected[key] {
+			t.Fatalf("Range(%v, %v) should be at %v, not %v.", from, to, key, i.Key())
+		}
+	}
+	if i.Next() {
+		t.Fatalf("Range(%v, %v) has too many elements (%v).", from, to, i.Key())
+	}
+	for j := len(keys) - 2; j >= 0; j-- {
+		if !i.Previous() || i.Key() != keys[j] {
+			t.Fatalf("Range(%v, %v) should be at %v going backward, not %v.", from, to, keys[j], i.Key())
+		}
+	}
+	if i.Previous() {
+		t.Fatalf("Range(%v, %v) has too many elements going backward (%v).", from, to, i.Key())
+	}
+	if err := i.Err(); err != nil {
+		t.Fatalf("Range(%v, %v) failed: %v.", from, to, err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const size = 300
+	s := openStore(t, dir)
+	expected := make(map[int]int)
+	for n := 0; n < 3000; n++ {
+		key := rand.Intn(size)
+		if rand.Intn(3) == 0 {
+			if err := s.Delete(key); err != nil {
+				t.Fatalf("Delete failed: %v.", err)
+			}
+			delete(expected, key)
+		} else {
+			if err := s.Set(key, n); err != nil {
+				t.Fatalf("Set failed: %v.", err)
+			}
+			expected[key] = n
+		}
+		if n%100 == 0 {
+			check(t, s, expected, size)
+		}
+	}
+	check(t, s, expected, size)
+	if len(s.tables) > 10 {
+		t.Errorf("Compaction should keep the number of tables low, not %v.", len(s.tables))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v.", err)
+	}
+
+	// A table that isn't in the manifest is removed.
+	stray := filepath.Join(dir, "999999.sst")
+	if err := ioutil.WriteFile(stray, []byte("garbage"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	s = openStore(t, dir)
+	defer s.Close()
+	check(t, s, expected, size)
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Errorf("Open should have removed the stray table (%v).", err)
+	}
+
+	i := s.Iterator()
+	count := 0
+	for i.Next() {
+		count++
+	}
+	if count != len(expected) {
+		t.Errorf("The store should have %v elements, not %v.", len(expected), count)
+	}
+	if len(expected) > 0 {
+		key := 0
+		for ; key < size; key++ {
+			if _, ok := expected[key]; ok {
+				break
+			}
+		}
+		if !i.Seek(key) || i.Key() != key {
+			t.Errorf("Seek(%v) should find %v, not %v.", key, key, i.Key())
+		}
+	}
+}
+
+func TestStoreCompactionDropsTombstones(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := openStore(t, dir)
+	defer s.Close()
+	// Three tables in the same tier: one with values, and two with
+	// tombstones. Merging them includes the oldest table, so the
+	// tombstones can go.
+	for key := 0; key < 16; key++ {
+		s.Set(key, key)
+	}
+	for n := 0; n < 2; n++ {
+		for key := 0; key < 16; key++ {
+			s.Delete(key)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v.", err)
+	}
+	entries := 0
+	for _, t := range s.tables {
+		entries += t.Len()
+	}
+	if entries != 0 {
+		t.Errorf("Compaction should have dropped all the entries, but %v are left in %v tables.", entries, len(s.tables))
+	}
+}
+
+func TestStoreFlushesDeletions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := openStore(t, dir)
+	expected := make(map[int]int)
+	for key := 0; key < 10; key++ {
+		s.Set(key, key)
+		expected[key] = key
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v.", err)
+	}
+	// A memtable holding only tombstones must be flushed too.
+	for key := 0; key < 5; key++ {
+		s.Delete(key)
+		delete(expected, key)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v.", err)
+	}
+
+	s = openStore(t, dir)
+	defer s.Close()
+	if len(s.tables) != 2 || s.tables[0].Len() != 5 {
+		t.Errorf("The newest table should hold the 5 tombstones.")
+	}
+	check(t, s, expected, 10)
+}
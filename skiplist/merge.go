@@ -0,0 +1,30 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// A MergeFunc combines the value associated with key (existing) with
+// an operand passed to Merge, returning the new value. For example, a
+// MergeFunc adding integers turns a SkipList into a map of counters.
+type MergeFunc func(key, existing, operand interface{}) interface{}
+
+// SetMergeFunc sets the function used by Merge to combine values.
+func (s *SkipList) SetMergeFunc(f MergeFunc) {
+	s.merge = f
+}
+
+// Merge combines operand with the value associated with key, using the
+// function set with SetMergeFunc. If key is not present in s, operand
+// is inserted as it is. Merge takes a single search, and it panics if
+// no merge function was set.
+func (s *SkipList) Merge(key, operand interface{}) {
+	if s.merge == nil {
+		panic("goskiplist: no merge function set")
+	}
+	s.update(key, func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return operand, true
+		}
+		return s.merge(key, old, operand), true
+	})
+}
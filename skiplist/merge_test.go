@@ -0,0 +1,41 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	s := NewIntMap()
+	s.SetMergeFunc(func(key, existing, operand interface{}) interface{} {
+		return existing.(int) + operand.(int)
+	})
+	for i := 0; i < 10; i++ {
+		s.Merge(i%3, i)
+	}
+	s.check(t, 0, 0+3+6+9)
+	s.check(t, 1, 1+4+7)
+	s.check(t, 2, 2+5+8)
+	if s.Len() != 3 {
+		t.Errorf("Len should be 3, not %v.", s.Len())
+	}
+
+	logs := NewStringMap()
+	logs.SetMergeFunc(func(key, existing, operand interface{}) interface{} {
+		return append(existing.([]string), operand.([]string)...)
+	})
+	logs.Merge("a", []string{"x"})
+	logs.Merge("a", []string{"y", "z"})
+	if value, _ := logs.Get("a"); len(value.([]string)) != 3 {
+		t.Errorf("The log of a should have 3 entries, not %v.", value)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Merge without a merge function should panic.")
+		}
+	}()
+	NewIntMap().Merge(1, 1)
+}
@@ -0,0 +1,128 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// prefixEnd returns the smallest key that is greater than all the keys
+// starting with prefix, or nil if there is no such key (for example,
+// when prefix is empty or consists only of 0xff bytes). prefix must be
+// a string or a []byte.
+func prefixEnd(prefix interface{}) interface{} {
+	switch p := prefix.(type) {
+	case string:
+		if end := bytesPrefixEnd([]byte(p)); end != nil {
+			return string(end)
+		}
+		return nil
+	case []byte:
+		if end := bytesPrefixEnd(p); end != nil {
+			return end
+		}
+		return nil
+	}
+	panic("goskiplist: prefixes must be strings or byte slices")
+}
+
+// bytesPrefixEnd increments the last byte of prefix that can be
+// incremented, dropping everything after it. Strings are compared
+// bytewise, so this works for multi-byte runes as well.
+func bytesPrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := make([]byte, i+1)
+			copy(end, prefix)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
+// PrefixIterator returns an iterator that will go through all the
+// elements of s whose keys start with prefix. It works only for skip
+// lists with string or []byte keys (see NewStringMap and NewBytesMap),
+// and prefix must be of the same type as the keys.
+func (s *SkipList) PrefixIterator(prefix interface{}) Iterator {
+	return s.Range(prefix, prefixEnd(prefix))
+}
+
+// CountPrefix returns the number of elements of s whose keys start
+// with prefix.
+func (s *SkipList) CountPrefix(prefix interface{}) (n int) {
+	i := s.PrefixIterator(prefix)
+	defer i.Close()
+
+	for i.Next() {
+		n++
+	}
+	return n
+}
+
+// DeletePrefix removes all the elements of s whose keys start with
+// prefix. It returns the number of removed elements. If tombstones are
+// enabled, it records a range tombstone for the prefix (without an
+// end, if the prefix is empty or consists only of 0xff bytes).
+func (s *SkipList) DeletePrefix(prefix interface{}) (removed int) {
+	return s.DeleteRange(prefix, prefixEnd(prefix))
+}
+
+// deleteRange removes all the nodes whose keys are greater or equal
+// than from, but less than to. If to is nil, all the nodes starting
+// from from are removed. It returns the number of removed nodes, not
+// counting tombstones.
+func (s *SkipList) deleteRange(from, to interface{}) (removed int) {
+	if s.deterministic {
+		// The gaps have to be fixed after every removal.
+		var keys []interface{}
+		for current := s.getPath(s.header, nil, from); current != nil && s.before(current.key, to); current = current.next() {
+			keys = append(keys, current.key)
+		}
+		for _, key := range keys {
+			if value, _ := s.unlink(key); !isTombstone(value) {
+				removed++
+			}
+		}
+		return removed
+	}
+
+	s.finger = nil
+	update := make([]*node, s.level()+1)
+	nodes, tombstones := 0, 0
+	for current := s.getPath(s.header, update, from); current != nil && s.before(current.key, to); current = current.next() {
+		nodes++
+		if isTombstone(current.value) {
+			tombstones++
+		}
+	}
+	if nodes == 0 {
+		return 0
+	}
+
+	for i := 0; i <= s.level(); i++ {
+		next := update[i].forward[i]
+		for next != nil && s.before(next.key, to) {
+			next = next.forward[i]
+		}
+		update[i].forward[i] = next
+	}
+
+	var previous *node
+	if update[0] != s.header {
+		previous = update[0]
+	}
+	if next := update[0].next(); next != nil {
+		next.backward = previous
+	} else {
+		s.footer = previous
+	}
+
+	s.length -= nodes
+	s.tombstones -= tombstones
+	s.trimLevels()
+	if s.bloom != nil {
+		s.bloom.stale += nodes - tombstones
+		s.bloom.maybeRebuild(s)
+	}
+
+	return nodes - tombstones
+}
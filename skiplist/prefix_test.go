@@ -0,0 +1,130 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	for _, c := range []struct {
+		prefix, end interface{}
+	}{
+		{"", nil},
+		{"a", "b"},
+		{"a/b/", "a/b0"},
+		{"a\xff", "b"},
+		{"\xff\xff", nil},
+		{"zaż", "za\xc5\xbd"},
+	} {
+		if end := prefixEnd(c.prefix); end != c.end {
+			t.Errorf("prefixEnd(%q) should be %q, not %q.", c.prefix, c.end, end)
+		}
+	}
+
+	if end := prefixEnd([]byte{1, 0xff}); !bytes.Equal(end.([]byte), []byte{2}) {
+		t.Errorf("prefixEnd([1 255]) should be [2], not %v.", end)
+	}
+
+	if end := prefixEnd([]byte{0xff}); end != nil {
+		t.Errorf("prefixEnd([255]) should be nil, not %v.", end)
+	}
+}
+
+func TestPrefixIterator(t *testing.T) {
+	s := NewStringMap()
+	for _, key := range []string{"/a", "/a/b", "/a/b/c", "/a/b/d", "/a/b\xff", "/a/bé", "/a/c", "/b"} {
+		s.Set(key, key)
+	}
+
+	var seen []string
+	for i := s.PrefixIterator("/a/b/"); i.Next(); {
+		seen = append(seen, i.Key().(string))
+	}
+	if len(seen) != 2 || seen[0] != "/a/b/c" || seen[1] != "/a/b/d" {
+		t.Errorf("PrefixIterator(\"/a/b/\") yielded %q.", seen)
+	}
+
+	if n := s.CountPrefix("/a/b"); n != 5 {
+		t.Errorf("CountPrefix(\"/a/b\") should be 5, not %v.", n)
+	}
+
+	if n := s.CountPrefix(""); n != s.Len() {
+		t.Errorf("CountPrefix(\"\") should be %v, not %v.", s.Len(), n)
+	}
+
+	if n := s.CountPrefix("/x"); n != 0 {
+		t.Errorf("CountPrefix(\"/x\") should be 0, not %v.", n)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	s := NewStringMap()
+	for _, key := range []string{"/a", "/a/b", "/a/b/c", "/a/b/d", "/a/bé", "/a/c", "/b"} {
+		s.Set(key, key)
+	}
+
+	if n := s.DeletePrefix("/a/b"); n != 4 {
+		t.Errorf("DeletePrefix(\"/a/b\") should have removed 4 elements, not %v.", n)
+	}
+	if l := s.Len(); l != 3 {
+		t.Errorf("Len should be 3, not %v.", l)
+	}
+
+	var seen []string
+	for i := s.Iterator(); i.Next(); {
+		seen = append(seen, i.Key().(string))
+	}
+	if len(seen) != 3 || seen[0] != "/a" || seen[1] != "/a/c" || seen[2] != "/b" {
+		t.Errorf("After DeletePrefix, the keys are %q.", seen)
+	}
+
+	for i := s.SeekToLast(); i.Previous(); {
+		if i.Key() == "/a/b" {
+			t.Errorf("Backward links still point to deleted nodes.")
+		}
+	}
+
+	if n := s.DeletePrefix("/b"); n != 1 {
+		t.Errorf("DeletePrefix(\"/b\") should have removed 1 element, not %v.", n)
+	}
+	if last := s.SeekToLast(); last.Key() != "/a/c" {
+		t.Errorf("The last key should be \"/a/c\", not %v.", last.Key())
+	}
+
+	if n := s.DeletePrefix(""); n != 2 || s.Len() != 0 || s.SeekToLast() != nil {
+		t.Errorf("DeletePrefix(\"\") should have emptied the list (removed %v, Len %v).", n, s.Len())
+	}
+}
+
+func TestBytesMapPrefix(t *testing.T) {
+	s := NewBytesMap()
+	for _, key := range [][]byte{{1}, {1, 0xff}, {1, 0xff, 0}, {1, 0xff, 0xff}, {2}} {
+		s.Set(key, len(key))
+	}
+
+	if n := s.CountPrefix([]byte{1, 0xff}); n != 3 {
+		t.Errorf("CountPrefix([1 255]) should be 3, not %v.", n)
+	}
+
+	if v, ok := s.Get([]byte{1, 0xff}); !ok || v != 2 {
+		t.Errorf("Get([1 255]) should be 2, true, not %v, %v.", v, ok)
+	}
+
+	s.Set([]byte{2}, 10)
+	if v, _ := s.Get([]byte{2}); v != 10 || s.Len() != 5 {
+		t.Errorf("Setting an existing []byte key should replace its value (got %v, Len %v).", v, s.Len())
+	}
+
+	if n := s.DeletePrefix([]byte{1}); n != 4 {
+		t.Errorf("DeletePrefix([1]) should have removed 4 elements, not %v.", n)
+	}
+
+	set := NewBytesSet()
+	set.Add([]byte("ala"))
+	if !set.Contains([]byte("ala")) {
+		t.Errorf("set should contain \"ala\".")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderOptions controls how WriteDOT and WriteASCII draw a skip
+// list.
+type RenderOptions struct {
+	// Format returns the label of a node. If it is nil, the key
+	// formatted with fmt.Sprint is used.
+	Format func(key, value interface{}) string
+	// MaxNodes is the maximum number of nodes that will be drawn.
+	// The remaining nodes are represented by an ellipsis. If it is
+	// 0 or less, all the nodes are drawn.
+	MaxNodes int
+}
+
+func (o *RenderOptions) label(n *node) string {
+	if o == nil || o.Format == nil {
+		return fmt.Sprint(n.key)
+	}
+	return o.Format(n.key, n.value)
+}
+
+// nodes returns the nodes of s that should be drawn, and whether
+// some nodes were omitted.
+func (o *RenderOptions) nodes(s *SkipList) (nodes []*node, truncated bool) {
+	for current := s.header.next(); current != nil; current = current.next() {
+		if o != nil && o.MaxNodes > 0 && len(nodes) == o.MaxNodes {
+			return nodes, true
+		}
+		nodes = append(nodes, current)
+	}
+	return nodes, false
+}
+
+// dotEscaper escapes the characters that are special in Graphviz
+// record labels.
+var dotEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`|`, `\|`,
+	`<`, `\<`,
+	`>`, `\>`,
+	"\n", `\n`,
+)
+
+// WriteDOT writes a Graphviz (http://www.graphviz.org) description of
+// s to w. Each node is drawn as a tower of forward links, with its
+// label at the bottom; backward links are drawn as dashed edges. opts
+// may be nil.
+func (s *SkipList) WriteDOT(w io.Writer, opts *RenderOptions) error {
+	nodes, truncated := opts.nodes(s)
+	ids := make(map[*node]string, len(nodes)+1)
+	ids[s.header] = "header"
+	for i, n := range nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+	}
+	target := func(n *node) string {
+		if n == nil {
+			return "end"
+		}
+		if id, ok := ids[n]; ok {
+			return id
+		}
+		return "more"
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph skiplist {\n")
+	fmt.Fprintf(b, "\trankdir=LR;\n")
+	fmt.Fprintf(b, "\tnode [shape=record];\n")
+
+	tower := func(n *node, label string) {
+		fmt.Fprintf(b, "\t%s [label=\"{", ids[n])
+		for i := len(n.forward) - 1; i >= 0; i-- {
+			fmt.Fprintf(b, "<l%d> %d|", i, i)
+		}
+		fmt.Fprintf(b, "%s}\"];\n", dotEscaper.Replace(label))
+	}
+	tower(s.header, "header")
+	for _, n := range nodes {
+		tower(n, opts.label(n))
+	}
+	if truncated {
+		fmt.Fprintf(b, "\tmore [shape=plaintext, label=\"...\"];\n")
+	}
+	fmt.Fprintf(b, "\tend [shape=plaintext, label=\"nil\"];\n")
+
+	for _, n := range append([]*node{s.header}, nodes...) {
+		for i, next := range n.forward {
+			to := target(next)
+			if to == "end" || to == "more" {
+				fmt.Fprintf(b, "\t%s:l%d -> %s;\n", ids[n], i, to)
+			} else {
+				fmt.Fprintf(b, "\t%s:l%d -> %s:l%d;\n", ids[n], i, to, i)
+			}
+		}
+		if n.backward != nil {
+			fmt.Fprintf(b, "\t%s:l0 -> %s:l0 [style=dashed];\n", ids[n], target(n.backward))
+		}
+	}
+	fmt.Fprintf(b, "}\n")
+
+	return b.Flush()
+}
+
+// WriteASCII draws s to w as text, with one row per level (the top
+// level first) and one column per node, for example:
+//
+//	2 head ----------------> 4 -> nil
+//	1 head ------> 2 ------> 4 -> nil
+//	0 head -> 1 -> 2 -> 3 -> 4 -> nil
+//
+// opts may be nil.
+func (s *SkipList) WriteASCII(w io.Writer, opts *RenderOptions) error {
+	nodes, truncated := opts.nodes(s)
+	labels := make([]string, len(nodes))
+	for i, n := range nodes {
+		labels[i] = opts.label(n)
+	}
+	end := "nil"
+	if truncated {
+		end = "..."
+	}
+	levelWidth := len(fmt.Sprint(s.level()))
+
+	b := bufio.NewWriter(w)
+	for level := s.level(); level >= 0; level-- {
+		fmt.Fprintf(b, "%*d head ", levelWidth, level)
+		for i, n := range nodes {
+			if len(n.forward) > level {
+				fmt.Fprintf(b, "-> %s ", labels[i])
+			} else {
+				fmt.Fprintf(b, "%s", strings.Repeat("-", utf8.RuneCountInString(labels[i])+4))
+			}
+		}
+		fmt.Fprintf(b, "-> %s\n", end)
+	}
+
+	return b.Flush()
+}
+
+// WriteDOT writes a Graphviz description of the underlying skip list
+// to w. See SkipList.WriteDOT.
+func (s *Set) WriteDOT(w io.Writer, opts *RenderOptions) error {
+	return s.skiplist.WriteDOT(w, opts)
+}
+
+// WriteASCII draws the underlying skip list to w. See
+// SkipList.WriteASCII.
+func (s *Set) WriteASCII(w io.Writer, opts *RenderOptions) error {
+	return s.skiplist.WriteASCII(w, opts)
+}
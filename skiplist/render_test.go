@@ -0,0 +1,138 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// makeTowers returns an int map with keys 1, 2, ..., len(heights),
+// where the tower of the i-th node has height heights[i].
+func makeTowers(heights ...int) *SkipList {
+	s := NewIntMap()
+	s.MaxLevel = 0
+	for i := range heights {
+		s.Set(i+1, i+1)
+	}
+
+	last := make([]*node, 0)
+	last = append(last, s.header)
+	s.header.forward = s.header.forward[:1]
+	for n, i := s.header.next(), 0; n != nil; n, i = n.next(), i+1 {
+		n.forward = append(n.forward[:1], make([]*node, heights[i]-1)...)
+		for level := 1; level < heights[i]; level++ {
+			if level == len(last) {
+				last = append(last, s.header)
+				s.header.forward = append(s.header.forward, nil)
+			}
+			last[level].forward[level] = n
+			last[level] = n
+		}
+	}
+	s.MaxLevel = DefaultMaxLevel
+	s.finger = nil
+	return s
+}
+
+func TestWriteASCII(t *testing.T) {
+	s := makeTowers(1, 2, 1, 3, 1, 1, 2)
+
+	var b bytes.Buffer
+	if err := s.WriteASCII(&b, nil); err != nil {
+		t.Fatalf("WriteASCII failed: %v.", err)
+	}
+	expected := strings.Join([]string{
+		"2 head ----------------> 4 ----------------> nil",
+		"1 head ------> 2 ------> 4 -----------> 7 -> nil",
+		"0 head -> 1 -> 2 -> 3 -> 4 -> 5 -> 6 -> 7 -> nil",
+		"",
+	}, "\n")
+	if b.String() != expected {
+		t.Errorf("WriteASCII wrote\n%s\ninstead of\n%s", b.String(), expected)
+	}
+
+	b.Reset()
+	opts := &RenderOptions{
+		Format: func(key, value interface{}) string {
+			return fmt.Sprintf("%v=%v", key, value)
+		},
+		MaxNodes: 2,
+	}
+	if err := s.WriteASCII(&b, opts); err != nil {
+		t.Fatalf("WriteASCII failed: %v.", err)
+	}
+	expected = strings.Join([]string{
+		"2 head ---------------> ...",
+		"1 head --------> 2=2 -> ...",
+		"0 head -> 1=1 -> 2=2 -> ...",
+		"",
+	}, "\n")
+	if b.String() != expected {
+		t.Errorf("WriteASCII wrote\n%s\ninstead of\n%s", b.String(), expected)
+	}
+}
+
+func TestWriteASCIIMultibyte(t *testing.T) {
+	s := makeTowers(1, 2)
+	var b bytes.Buffer
+	opts := &RenderOptions{
+		Format: func(key, value interface{}) string {
+			return []string{"", "żółw", "λ"}[key.(int)]
+		},
+	}
+	if err := s.WriteASCII(&b, opts); err != nil {
+		t.Fatalf("WriteASCII failed: %v.", err)
+	}
+	expected := strings.Join([]string{
+		"1 head ---------> λ -> nil",
+		"0 head -> żółw -> λ -> nil",
+		"",
+	}, "\n")
+	if b.String() != expected {
+		t.Errorf("WriteASCII wrote\n%s\ninstead of\n%s", b.String(), expected)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	s := makeTowers(1, 2, 1)
+
+	var b bytes.Buffer
+	if err := s.WriteDOT(&b, nil); err != nil {
+		t.Fatalf("WriteDOT failed: %v.", err)
+	}
+	for _, line := range []string{
+		"digraph skiplist {",
+		`header [label="{<l1> 1|<l0> 0|header}"];`,
+		`n1 [label="{<l1> 1|<l0> 0|2}"];`,
+		"header:l1 -> n1:l1;",
+		"n1:l1 -> end;",
+		"n0:l0 -> n1:l0;",
+		"n2:l0 -> end;",
+		"n2:l0 -> n1:l0 [style=dashed];",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("WriteDOT output doesn't contain %q:\n%s", line, b.String())
+		}
+	}
+
+	b.Reset()
+	set := NewStringSet()
+	set.Add("a|b")
+	set.Add("c")
+	if err := set.WriteDOT(&b, &RenderOptions{MaxNodes: 1}); err != nil {
+		t.Fatalf("WriteDOT failed: %v.", err)
+	}
+	for _, line := range []string{
+		`|a\|b}"];`,
+		"n0:l0 -> more;",
+		`more [shape=plaintext, label="..."];`,
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("WriteDOT output doesn't contain %q:\n%s", line, b.String())
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package skiplist
 
 import (
+	"bytes"
+	"math"
 	"math/rand"
 )
 
@@ -73,9 +75,30 @@ func (n *node) hasPrevious() bool {
 //	}
 type SkipList struct {
 	lessThan func(l, r interface{}) bool
-	header   *node
-	footer   *node
-	length   int
+	// equal is used to decide whether two keys are the same. If
+	// it is nil, keys are compared using ==.
+	equal  func(l, r interface{}) bool
+	header *node
+	footer *node
+	length int
+	// checker, if not nil, checks the comparison function.
+	checker *comparatorChecker
+	// deterministic is true for 1-2-3 skip lists (see
+	// NewDeterministicMap).
+	deterministic bool
+	// finger is the update vector of the last key that was set
+	// or deleted. It is nil if it isn't known.
+	finger []*node
+	// merge combines values in Merge (see SetMergeFunc).
+	merge MergeFunc
+	// ranges holds the range tombstones. It is nil unless
+	// tombstones are enabled (see EnableTombstones).
+	ranges *IntervalMap
+	// tombstones is the number of tombstone nodes.
+	tombstones int
+	// bloom, if not nil, is a Bloom filter of the keys (see
+	// EnableBloomFilter).
+	bloom *bloomFilter
 	// MaxLevel determines how many items the SkipList can store
 	// efficiently (2^MaxLevel).
 	//
@@ -89,11 +112,24 @@ type SkipList struct {
 	// standard linked list and will not have any of the nice
 	// properties of skip lists (probably not what you want).
 	MaxLevel int
+	// AutoMaxLevel makes the maximum level follow the length of
+	// the SkipList: it is kept around log_{1/p}(Len()), but never
+	// above MaxLevel. When the list shrinks, towers that became
+	// too high are cut.
+	AutoMaxLevel bool
 }
 
-// Len returns the length of s.
+// Len returns the length of s, not counting tombstones.
 func (s *SkipList) Len() int {
-	return s.length
+	return s.length - s.tombstones
+}
+
+// keysEqual returns true if l and r are the same key.
+func (s *SkipList) keysEqual(l, r interface{}) bool {
+	if s.equal != nil {
+		return s.equal(l, r)
+	}
+	return l == r
 }
 
 // Iterator is an interface that you can use to iterate through the
@@ -139,11 +175,12 @@ func (i iter) Value() interface{} {
 }
 
 func (i *iter) Next() bool {
-	if !i.current.hasNext() {
+	next := i.list.visible(i.current.next())
+	if next == nil {
 		return false
 	}
 
-	i.current = i.current.next()
+	i.current = next
 	i.key = i.current.key
 	i.value = i.current.value
 
@@ -151,11 +188,12 @@ func (i *iter) Next() bool {
 }
 
 func (i *iter) Previous() bool {
-	if !i.current.hasPrevious() {
+	previous := i.list.visibleBackward(i.current.previous())
+	if previous == nil {
 		return false
 	}
 
-	i.current = i.current.previous()
+	i.current = previous
 	i.key = i.current.key
 	i.value = i.current.value
 
@@ -187,7 +225,7 @@ func (i *iter) Seek(key interface{}) (ok bool) {
 		current = current.backward
 	}
 
-	current = list.getPath(current, nil, key)
+	current = list.visible(list.getPath(current, nil, key))
 
 	if current == nil {
 		return
@@ -214,34 +252,32 @@ type rangeIterator struct {
 }
 
 func (i *rangeIterator) Next() bool {
-	if !i.current.hasNext() {
+	next := i.list.visible(i.current.next())
+	if next == nil {
 		return false
 	}
 
-	next := i.current.next()
-
-	if !i.list.lessThan(next.key, i.upperLimit) {
+	if !i.list.before(next.key, i.upperLimit) {
 		return false
 	}
 
-	i.current = i.current.next()
+	i.current = next
 	i.key = i.current.key
 	i.value = i.current.value
 	return true
 }
 
 func (i *rangeIterator) Previous() bool {
-	if !i.current.hasPrevious() {
+	previous := i.list.visibleBackward(i.current.previous())
+	if previous == nil {
 		return false
 	}
 
-	previous := i.current.previous()
-
 	if i.list.lessThan(previous.key, i.lowerLimit) {
 		return false
 	}
 
-	i.current = i.current.previous()
+	i.current = previous
 	i.key = i.current.key
 	i.value = i.current.value
 	return true
@@ -250,7 +286,7 @@ func (i *rangeIterator) Previous() bool {
 func (i *rangeIterator) Seek(key interface{}) (ok bool) {
 	if i.list.lessThan(key, i.lowerLimit) {
 		return
-	} else if !i.list.lessThan(key, i.upperLimit) {
+	} else if !i.list.before(key, i.upperLimit) {
 		return
 	}
 
@@ -263,6 +299,12 @@ func (i *rangeIterator) Close() {
 	i.lowerLimit = nil
 }
 
+// before returns true if key is less than limit. A nil limit is
+// greater than any key.
+func (s *SkipList) before(key, limit interface{}) bool {
+	return limit == nil || s.lessThan(key, limit)
+}
+
 // Iterator returns an Iterator that will go through all elements s.
 func (s *SkipList) Iterator() Iterator {
 	return &iter{
@@ -274,7 +316,7 @@ func (s *SkipList) Iterator() Iterator {
 // Seek returns a bidirectional iterator starting with the first element whose
 // key is greater or equal to key; otherwise, a nil iterator is returned.
 func (s *SkipList) Seek(key interface{}) Iterator {
-	current := s.getPath(s.header, nil, key)
+	current := s.visible(s.getPath(s.header, nil, key))
 	if current == nil {
 		return nil
 	}
@@ -290,12 +332,11 @@ func (s *SkipList) Seek(key interface{}) Iterator {
 // SeekToFirst returns a bidirectional iterator starting from the first element
 // in the list if the list is populated; otherwise, a nil iterator is returned.
 func (s *SkipList) SeekToFirst() Iterator {
-	if s.length == 0 {
+	current := s.visible(s.header.next())
+	if current == nil {
 		return nil
 	}
 
-	current := s.header.next()
-
 	return &iter{
 		current: current,
 		key:     current.key,
@@ -307,7 +348,7 @@ func (s *SkipList) SeekToFirst() Iterator {
 // SeekToLast returns a bidirectional iterator starting from the last element
 // in the list if the list is populated; otherwise, a nil iterator is returned.
 func (s *SkipList) SeekToLast() Iterator {
-	current := s.footer
+	current := s.visibleBackward(s.footer)
 	if current == nil {
 		return nil
 	}
@@ -349,13 +390,36 @@ func maxInt(x, y int) int {
 	return y
 }
 
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// maxLevel returns the level new towers shouldn't exceed. It is
+// MaxLevel, unless AutoMaxLevel is set.
+func (s *SkipList) maxLevel() int {
+	if !s.AutoMaxLevel {
+		return s.MaxLevel
+	}
+	expected := int(math.Ceil(math.Log(float64(s.length+1)) / math.Log(1/p)))
+	return minInt(expected, s.MaxLevel)
+}
+
 func (s *SkipList) effectiveMaxLevel() int {
-	return maxInt(s.level(), s.MaxLevel)
+	return maxInt(s.level(), s.maxLevel())
 }
 
 // Returns a new random level.
 func (s SkipList) randomLevel() (n int) {
-	for n = 0; n < s.effectiveMaxLevel() && rand.Float64() < p; n++ {
+	return randomLevelUpTo(s.effectiveMaxLevel())
+}
+
+// randomLevelUpTo returns a new random level that is not greater than
+// max.
+func randomLevelUpTo(max int) (n int) {
+	for n = 0; n < max && rand.Float64() < p; n++ {
 	}
 	return
 }
@@ -364,9 +428,15 @@ func (s SkipList) randomLevel() (n int) {
 // not present in s). The second return value is true when the key is
 // present.
 func (s *SkipList) Get(key interface{}) (value interface{}, ok bool) {
-	candidate := s.getPath(s.header, nil, key)
+	if s.bloom != nil && !s.bloom.mayContain(key) {
+		return nil, false
+	}
+	candidate := s.search(nil, key)
 
-	if candidate == nil || candidate.key != key {
+	if candidate == nil || !s.keysEqual(candidate.key, key) || s.hidden(candidate) {
+		if s.bloom != nil {
+			s.bloom.falsePositive()
+		}
 		return nil, false
 	}
 
@@ -377,7 +447,7 @@ func (s *SkipList) Get(key interface{}) (value interface{}, ok bool) {
 // to min. It returns its value, its actual key, and whether such a
 // node is present in the skip list.
 func (s *SkipList) GetGreaterOrEqual(min interface{}) (actualKey, value interface{}, ok bool) {
-	candidate := s.getPath(s.header, nil, min)
+	candidate := s.visible(s.getPath(s.header, nil, min))
 
 	if candidate != nil {
 		return candidate.key, candidate.value, true
@@ -385,14 +455,42 @@ func (s *SkipList) GetGreaterOrEqual(min interface{}) (actualKey, value interfac
 	return nil, nil, false
 }
 
+// GetLessOrEqual finds the node whose key is less than or equal to
+// max. It returns its value, its actual key, and whether such a node
+// is present in the skip list.
+func (s *SkipList) GetLessOrEqual(max interface{}) (actualKey, value interface{}, ok bool) {
+	candidate := s.getPath(s.header, nil, max)
+
+	if candidate == nil || !s.keysEqual(candidate.key, max) {
+		candidate = s.lastBefore(candidate)
+	}
+	if candidate = s.visibleBackward(candidate); candidate != nil {
+		return candidate.key, candidate.value, true
+	}
+	return nil, nil, false
+}
+
+// lastBefore returns the node preceding next, which may be nil for the
+// end of the list. It returns nil if next is the first node.
+func (s *SkipList) lastBefore(next *node) *node {
+	if next == nil {
+		return s.footer
+	}
+	return next.backward
+}
+
 // getPath populates update with nodes that constitute the path to the
 // node that may contain key. The candidate node will be returned. If
 // update is nil, it will be left alone (the candidate node will still
 // be returned). If update is not nil, but it doesn't have enough
 // slots for all the nodes in the path, getPath will panic.
 func (s *SkipList) getPath(current *node, update []*node, key interface{}) *node {
-	depth := len(current.forward) - 1
+	return s.descend(current, len(current.forward)-1, update, key)
+}
 
+// descend works like getPath, but it starts at the given level of
+// current instead of its highest level.
+func (s *SkipList) descend(current *node, depth int, update []*node, key interface{}) *node {
 	for i := depth; i >= 0; i-- {
 		for current.forward[i] != nil && s.lessThan(current.forward[i].key, key) {
 			current = current.forward[i]
@@ -409,13 +507,40 @@ func (s *SkipList) Set(key, value interface{}) {
 	if key == nil {
 		panic("goskiplist: nil keys are not supported")
 	}
+	s.set(key, value)
+}
+
+// set sets the value associated with key in s. It returns the old
+// value, and whether key was present in s before.
+func (s *SkipList) set(key, value interface{}) (old interface{}, existed bool) {
+	if s.checker != nil {
+		s.checker.observe(key)
+	}
 	// s.level starts from 0, so we need to allocate one.
 	update := make([]*node, s.level()+1, s.effectiveMaxLevel()+1)
-	candidate := s.getPath(s.header, update, key)
+	candidate := s.search(update, key)
 
-	if candidate != nil && candidate.key == key {
-		candidate.value = value
-		return
+	if candidate != nil && s.keysEqual(candidate.key, key) {
+		old, existed = candidate.value, true
+		s.replace(candidate, value)
+	} else {
+		s.insert(update, key, value)
+	}
+	s.setFinger(update)
+	return old, existed
+}
+
+// insert adds a new node after update[0], which must be the result of
+// a call to getPath for key. It returns the new node.
+func (s *SkipList) insert(update []*node, key, value interface{}) *node {
+	if isTombstone(value) {
+		s.tombstones++
+	} else if s.bloom != nil {
+		s.bloom.maybeRebuild(s)
+		s.bloom.add(key)
+	}
+	if s.deterministic {
+		return s.insertDeterministic(update, key, value)
 	}
 
 	newLevel := s.randomLevel()
@@ -453,24 +578,67 @@ func (s *SkipList) Set(key, value interface{}) {
 		}
 	}
 
-	if s.footer == nil || s.lessThan(s.footer.key, key) {
+	if newNode.forward[0] == nil {
 		s.footer = newNode
 	}
+
+	return newNode
 }
 
-// Delete removes the node with the given key.
+// Delete removes the node with the given key. If tombstones are
+// enabled (see EnableTombstones), it records a tombstone for the key
+// instead.
 //
 // It returns the old value and whether the node was present.
 func (s *SkipList) Delete(key interface{}) (value interface{}, ok bool) {
 	if key == nil {
 		panic("goskiplist: nil keys are not supported")
 	}
-	update := make([]*node, s.level()+1, s.effectiveMaxLevel())
-	candidate := s.getPath(s.header, update, key)
+	return s.delete(key)
+}
 
-	if candidate == nil || candidate.key != key {
+// delete removes the node with the given key, or records a tombstone
+// for it if tombstones are enabled.
+func (s *SkipList) delete(key interface{}) (value interface{}, ok bool) {
+	if s.ranges != nil {
+		if old, existed := s.set(key, tombstone{}); existed && !isTombstone(old) {
+			return old, true
+		}
 		return nil, false
 	}
+	return s.unlink(key)
+}
+
+// unlink removes the node with the given key, even if tombstones are
+// enabled.
+func (s *SkipList) unlink(key interface{}) (value interface{}, ok bool) {
+	update := make([]*node, s.level()+1)
+	candidate := s.search(update, key)
+
+	if candidate == nil || !s.keysEqual(candidate.key, key) {
+		return nil, false
+	}
+
+	value = candidate.value
+	s.remove(update, candidate)
+	s.setFinger(update)
+
+	return value, true
+}
+
+// remove unlinks candidate from s. update must be the result of a call
+// to getPath for the key of candidate.
+func (s *SkipList) remove(update []*node, candidate *node) {
+	if isTombstone(candidate.value) {
+		s.tombstones--
+	} else if s.bloom != nil {
+		s.bloom.maybeRebuild(s)
+		s.bloom.stale++
+	}
+	if s.deterministic {
+		s.removeDeterministic(update, candidate)
+		return
+	}
 
 	previous := candidate.backward
 	if s.footer == candidate {
@@ -486,12 +654,26 @@ func (s *SkipList) Delete(key interface{}) (value interface{}, ok bool) {
 		update[i].forward[i] = candidate.forward[i]
 	}
 
+	s.length--
+	s.trimLevels()
+}
+
+// trimLevels drops the empty top levels of the header. If
+// AutoMaxLevel is set, it also cuts the towers that are more than one
+// level higher than the maximum level.
+func (s *SkipList) trimLevels() {
 	for s.level() > 0 && s.header.forward[s.level()] == nil {
 		s.header.forward = s.header.forward[:s.level()]
 	}
-	s.length--
 
-	return candidate.value, true
+	if max := s.maxLevel(); s.AutoMaxLevel && !s.deterministic && s.level() > max+1 {
+		for current := s.header.forward[max+1]; current != nil; {
+			next := current.forward[max+1]
+			current.forward = current.forward[:max+1]
+			current = next
+		}
+		s.header.forward = s.header.forward[:max+1]
+	}
 }
 
 // NewCustomMap returns a new SkipList that will use lessThan as the
@@ -540,6 +722,17 @@ func NewStringMap() *SkipList {
 	})
 }
 
+// NewBytesMap returns a SkipList that accepts []byte keys.
+func NewBytesMap() *SkipList {
+	s := NewCustomMap(func(l, r interface{}) bool {
+		return bytes.Compare(l.([]byte), r.([]byte)) < 0
+	})
+	s.equal = func(l, r interface{}) bool {
+		return bytes.Equal(l.([]byte), r.([]byte))
+	}
+	return s
+}
+
 // Set is an ordered set data structure.
 //
 // Its elements must implement the Ordered interface. It uses a
@@ -568,13 +761,7 @@ func NewSet() *Set {
 // comparison function. lessThan should define a linear order on
 // elements you intend to use with the Set.
 func NewCustomSet(lessThan func(l, r interface{}) bool) *Set {
-	return &Set{skiplist: SkipList{
-		lessThan: lessThan,
-		header: &node{
-			forward: []*node{nil},
-		},
-		MaxLevel: DefaultMaxLevel,
-	}}
+	return &Set{skiplist: *NewCustomMap(lessThan)}
 }
 
 // NewIntSet returns a new Set that accepts int elements.
@@ -591,6 +778,11 @@ func NewStringSet() *Set {
 	})
 }
 
+// NewBytesSet returns a new Set that accepts []byte elements.
+func NewBytesSet() *Set {
+	return &Set{skiplist: *NewBytesMap()}
+}
+
 // Add adds key to s.
 func (s *Set) Add(key interface{}) {
 	s.skiplist.Set(key, nil)
@@ -633,3 +825,8 @@ func (s *Set) SetMaxLevel(newMaxLevel int) {
 func (s *Set) GetMaxLevel() int {
 	return s.skiplist.MaxLevel
 }
+
+// SetAutoMaxLevel sets AutoMaxLevel in the underlying skip list.
+func (s *Set) SetAutoMaxLevel(auto bool) {
+	s.skiplist.AutoMaxLevel = auto
+}
@@ -137,6 +137,33 @@ func TestGetGreaterOrEqual(t *testing.T) {
 	}
 }
 
+func TestGetLessOrEqual(t *testing.T) {
+	s := NewIntMap()
+
+	if _, value, present := s.GetLessOrEqual(5); !(value == nil && !present) {
+		t.Errorf("s.GetLessOrEqual(5) should have returned nil and false for an empty map, not %v and %v.", value, present)
+	}
+
+	s.Set(10, 10)
+
+	if _, value, present := s.GetLessOrEqual(5); !(value == nil && !present) {
+		t.Errorf("s.GetLessOrEqual(5) should have returned nil and false, not %v and %v.", value, present)
+	}
+
+	s.Set(0, 0)
+	s.Set(5, 5)
+
+	if key, value, present := s.GetLessOrEqual(5); !(value == 5 && key == 5 && present) {
+		t.Errorf("s.GetLessOrEqual(5) should have returned 5 and true, not %v and %v.", value, present)
+	}
+	if key, value, present := s.GetLessOrEqual(7); !(value == 5 && key == 5 && present) {
+		t.Errorf("s.GetLessOrEqual(7) should have returned 5 and true, not %v and %v.", value, present)
+	}
+	if key, value, present := s.GetLessOrEqual(100); !(value == 10 && key == 10 && present) {
+		t.Errorf("s.GetLessOrEqual(100) should have returned 10 and true, not %v and %v.", value, present)
+	}
+}
+
 func TestSet(t *testing.T) {
 	s := NewIntMap()
 	if l := s.Len(); l != 0 {
@@ -0,0 +1,102 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math"
+	"unsafe"
+)
+
+// Stats describes the shape of a skip list. It is meant to help
+// choosing MaxLevel for a particular use.
+type Stats struct {
+	// Len is the number of elements in the list, as returned by
+	// SkipList.Len. It doesn't count tombstones.
+	Len int
+	// Tombstones is the number of tombstone nodes (see
+	// SkipList.EnableTombstones). They are included in the other
+	// statistics, as they take part in searches like any other
+	// node.
+	Tombstones int
+	// Level is the highest level used by the list (see
+	// SkipList.level); it is 0 if all towers have height 1.
+	Level int
+	// NodesPerLevel holds the number of nodes present at each
+	// level, starting from level 0 (which contains all of them).
+	NodesPerLevel []int
+	// AverageHeight is the average tower height.
+	AverageHeight float64
+	// MaxHeight is the height of the highest tower.
+	MaxHeight int
+	// ExpectedSearchCost is the expected number of steps needed
+	// to find a key, estimated from the length of the list using
+	// Pugh's bound L(n)/p + 1/(1-p), where L(n) = log_{1/p} n.
+	// It assumes towers of random heights without a limit.
+	ExpectedSearchCost float64
+	// AverageSearchCost is the average number of steps needed to
+	// find a key of the list, measured on the towers that were
+	// actually built: a move to the next node counts as a step, as
+	// does dropping to a lower level. If it is much higher than
+	// ExpectedSearchCost, MaxLevel is too low for the data, or the
+	// list needs to be rebalanced.
+	AverageSearchCost float64
+	// MemoryBytes estimates the number of bytes used by the nodes
+	// (including the header) and their forward slices. It doesn't
+	// include the memory used by keys and values.
+	MemoryBytes int64
+}
+
+const (
+	nodeSize    = int64(unsafe.Sizeof(node{}))
+	pointerSize = int64(unsafe.Sizeof((*node)(nil)))
+)
+
+// Stats returns statistics describing the structure of s. It takes
+// O(n) time.
+func (s *SkipList) Stats() Stats {
+	stats := Stats{
+		Len:           s.Len(),
+		Tombstones:    s.tombstones,
+		Level:         s.level(),
+		NodesPerLevel: make([]int, s.level()+1),
+		MemoryBytes:   nodeSize + int64(cap(s.header.forward))*pointerSize,
+	}
+
+	// run[i] is the number of nodes reaching level i, but not
+	// i+1, since the last node reaching level i+1. A search for
+	// the next node moves through all of them, so it takes
+	// sum(run) + Level + 1 steps.
+	run := make([]int, s.level()+1)
+	total, steps, searchCost := 0, 0, 0
+	for current := s.header.next(); current != nil; current = current.next() {
+		searchCost += steps + s.level() + 1
+		height := len(current.forward)
+		for i := 0; i < height-1; i++ {
+			steps -= run[i]
+			run[i] = 0
+		}
+		run[height-1]++
+		steps++
+		for i := 0; i < height; i++ {
+			stats.NodesPerLevel[i]++
+		}
+		total += height
+		stats.MaxHeight = maxInt(stats.MaxHeight, height)
+		stats.MemoryBytes += nodeSize + int64(cap(current.forward))*pointerSize
+	}
+
+	if s.length > 0 {
+		stats.AverageHeight = float64(total) / float64(s.length)
+		stats.AverageSearchCost = float64(searchCost) / float64(s.length)
+		stats.ExpectedSearchCost = math.Log(float64(s.length))/math.Log(1/p)/p + 1/(1-p)
+	}
+
+	return stats
+}
+
+// Stats returns statistics describing the structure of the
+// underlying skip list. See SkipList.Stats.
+func (s *Set) Stats() Stats {
+	return s.skiplist.Stats()
+}
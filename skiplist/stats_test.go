@@ -0,0 +1,76 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s := NewIntMap()
+	stats := s.Stats()
+	if stats.Len != 0 || stats.Level != 0 || stats.MaxHeight != 0 || stats.ExpectedSearchCost != 0 {
+		t.Errorf("Wrong stats for an empty list: %+v.", stats)
+	}
+	if stats.MemoryBytes <= 0 {
+		t.Errorf("The header should take some memory, got %v.", stats.MemoryBytes)
+	}
+
+	for i := 0; i < 10000; i++ {
+		s.Set(i, i)
+	}
+	stats = s.Stats()
+
+	if stats.Len != 10000 {
+		t.Errorf("Len should be 10000, not %v.", stats.Len)
+	}
+	if stats.Level != s.level() || len(stats.NodesPerLevel) != s.level()+1 {
+		t.Errorf("Level should be %v, not %v (%v levels in the histogram).", s.level(), stats.Level, len(stats.NodesPerLevel))
+	}
+	if stats.NodesPerLevel[0] != 10000 {
+		t.Errorf("All the nodes should be at level 0, got %v.", stats.NodesPerLevel[0])
+	}
+	for i := 1; i < len(stats.NodesPerLevel); i++ {
+		if stats.NodesPerLevel[i] > stats.NodesPerLevel[i-1] {
+			t.Errorf("Level %d has more nodes than the level below: %v.", i, stats.NodesPerLevel)
+		}
+	}
+	if stats.MaxHeight != stats.Level+1 {
+		t.Errorf("MaxHeight should be %v, not %v.", stats.Level+1, stats.MaxHeight)
+	}
+	// With p = 1/4, the average height is 1/(1-p) = 1.33.
+	if stats.AverageHeight < 1.2 || stats.AverageHeight > 1.5 {
+		t.Errorf("Suspicious average height: %v.", stats.AverageHeight)
+	}
+	// log_4(10000)/0.25 + 1/0.75 = 27.9
+	if stats.ExpectedSearchCost < 27 || stats.ExpectedSearchCost > 29 {
+		t.Errorf("Suspicious expected search cost: %v.", stats.ExpectedSearchCost)
+	}
+	if min := 10000 * nodeSize; stats.MemoryBytes < min {
+		t.Errorf("MemoryBytes should be at least %v, not %v.", min, stats.MemoryBytes)
+	}
+}
+
+func TestStatsAverageSearchCost(t *testing.T) {
+	for _, maxLevel := range []int{0, 1, DefaultMaxLevel} {
+		s := NewIntMap()
+		s.MaxLevel = maxLevel
+		for i := 0; i < 1000; i++ {
+			s.Set(i, i)
+		}
+
+		total := 0
+		for i := 0; i < 1000; i++ {
+			total += s.searchCost(i)
+		}
+		stats := s.Stats()
+		if expected := float64(total) / 1000; math.Abs(stats.AverageSearchCost-expected) > 1e-9 {
+			t.Errorf("With MaxLevel %d, AverageSearchCost should be %v, not %v.", maxLevel, expected, stats.AverageSearchCost)
+		}
+		if maxLevel == 0 && stats.AverageSearchCost < 10*stats.ExpectedSearchCost {
+			t.Errorf("A linked list should be much slower than expected, not %v (expected %v).", stats.AverageSearchCost, stats.ExpectedSearchCost)
+		}
+	}
+}
@@ -0,0 +1,620 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// A table file, as written by FlushTo, consists of:
+//
+//	data blocks
+//	range tombstones
+//	index
+//	footer
+//
+// Every data block holds consecutive entries in key order, each of
+// them being a kind (opSet for a value, opDelete for a tombstone), the
+// length of the encoded key (a uvarint), the encoded key and, for
+// values, the length of the encoded value and the encoded value. A
+// block is closed when it reaches tableBlockSize bytes. The range
+// tombstones are the length of the encoded start and the encoded start,
+// followed by 0 for a range without an end, or by 1, the length of the
+// encoded end and the encoded end. The index has one entry per block:
+// its offset, its length and its CRC-32 checksum (uvarints), followed
+// by the length of its first key and its first key. The footer holds
+// the offset and the length of the index, the length and the CRC-32
+// checksum of the range tombstones, the number of entries, and a magic
+// number, as big endian uint64s.
+
+const (
+	tableBlockSize  = 4096
+	tableFooterSize = 48
+	tableMagic      = 0x676f736b69706c73 // "goskipls"
+)
+
+// FlushTo writes the elements of s to w as a table file, in key order,
+// using keys and values to serialize them. The file can be read with
+// OpenTable. Tombstones and range tombstones (see EnableTombstones)
+// are written too.
+func (s *SkipList) FlushTo(w io.Writer, keys, values Codec) error {
+	t := NewTableWriter(w, keys, values)
+	for current := s.header.next(); current != nil; current = current.next() {
+		var err error
+		if s.hidden(current) {
+			err = t.AddTombstone(current.key)
+		} else {
+			err = t.Add(current.key, current.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if s.ranges != nil {
+		for i := s.ranges.Iterator(); i.Next(); {
+			interval := i.Value().(Interval)
+			if err := t.AddRangeTombstone(interval.From, interval.To); err != nil {
+				return err
+			}
+		}
+	}
+	return t.Close()
+}
+
+// A TableWriter writes a table file incrementally, for example to
+// merge several tables without holding their elements in memory.
+type TableWriter struct {
+	w            *bufio.Writer
+	keys, values Codec
+	offset       int64
+	length       int
+	block        []byte
+	firstKey     []byte
+	ranges       []byte
+	index        []byte
+	err          error
+}
+
+// NewTableWriter returns a TableWriter writing to w, using keys and
+// values to serialize the elements.
+func NewTableWriter(w io.Writer, keys, values Codec) *TableWriter {
+	return &TableWriter{
+		w:      bufio.NewWriter(w),
+		keys:   keys,
+		values: values,
+	}
+}
+
+func appendUvarint(dst []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(dst, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+// Add appends an element to the table. The elements and the
+// tombstones must be added in strictly increasing key order.
+func (t *TableWriter) Add(key, value interface{}) error {
+	return t.add(opSet, key, value)
+}
+
+// AddTombstone appends a tombstone, recording that key was deleted,
+// to the table.
+func (t *TableWriter) AddTombstone(key interface{}) error {
+	return t.add(opDelete, key, nil)
+}
+
+func (t *TableWriter) add(op byte, key, value interface{}) error {
+	if t.err != nil {
+		return t.err
+	}
+	encodedKey, err := t.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	var encodedValue []byte
+	if op == opSet {
+		if encodedValue, err = t.values.Encode(value); err != nil {
+			return err
+		}
+	}
+	if len(t.block) == 0 {
+		t.firstKey = encodedKey
+	}
+	t.block = append(t.block, op)
+	t.block = appendUvarint(t.block, uint64(len(encodedKey)))
+	t.block = append(t.block, encodedKey...)
+	if op == opSet {
+		t.block = appendUvarint(t.block, uint64(len(encodedValue)))
+		t.block = append(t.block, encodedValue...)
+	}
+	t.length++
+	if len(t.block) >= tableBlockSize {
+		t.flushBlock()
+	}
+	return t.err
+}
+
+// AddRangeTombstone records that the keys in [from, to) were deleted
+// (all the keys from from on, if to is nil). The entries added to the
+// table are newer than its range tombstones, so they are not covered by
+// them. Range tombstones may be added in any order.
+func (t *TableWriter) AddRangeTombstone(from, to interface{}) error {
+	if t.err != nil {
+		return t.err
+	}
+	encodedFrom, err := t.keys.Encode(from)
+	if err != nil {
+		return err
+	}
+	var encodedTo []byte
+	if to != nil {
+		if encodedTo, err = t.keys.Encode(to); err != nil {
+			return err
+		}
+	}
+	t.ranges = appendUvarint(t.ranges, uint64(len(encodedFrom)))
+	t.ranges = append(t.ranges, encodedFrom...)
+	if to == nil {
+		t.ranges = append(t.ranges, 0)
+		return nil
+	}
+	t.ranges = append(t.ranges, 1)
+	t.ranges = appendUvarint(t.ranges, uint64(len(encodedTo)))
+	t.ranges = append(t.ranges, encodedTo...)
+	return nil
+}
+
+// flushBlock writes the current block, and adds it to the index.
+func (t *TableWriter) flushBlock() {
+	if len(t.block) == 0 || t.err != nil {
+		return
+	}
+	if _, t.err = t.w.Write(t.block); t.err != nil {
+		return
+	}
+	t.index = appendUvarint(t.index, uint64(t.offset))
+	t.index = appendUvarint(t.index, uint64(len(t.block)))
+	t.index = appendUvarint(t.index, uint64(crc32.ChecksumIEEE(t.block)))
+	t.index = appendUvarint(t.index, uint64(len(t.firstKey)))
+	t.index = append(t.index, t.firstKey...)
+	t.offset += int64(len(t.block))
+	t.block = t.block[:0]
+}
+
+// Close writes the last block, the range tombstones, the index and the
+// footer. It doesn't close the underlying writer.
+func (t *TableWriter) Close() error {
+	t.flushBlock()
+	if t.err != nil {
+		return t.err
+	}
+
+	var footer [tableFooterSize]byte
+	binary.BigEndian.PutUint64(footer[0:], uint64(t.offset)+uint64(len(t.ranges)))
+	binary.BigEndian.PutUint64(footer[8:], uint64(len(t.index)))
+	binary.BigEndian.PutUint64(footer[16:], uint64(len(t.ranges)))
+	binary.BigEndian.PutUint64(footer[24:], uint64(crc32.ChecksumIEEE(t.ranges)))
+	binary.BigEndian.PutUint64(footer[32:], uint64(t.length))
+	binary.BigEndian.PutUint64(footer[40:], tableMagic)
+	if _, err := t.w.Write(t.ranges); err != nil {
+		return err
+	}
+	if _, err := t.w.Write(t.index); err != nil {
+		return err
+	}
+	if _, err := t.w.Write(footer[:]); err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+// A Table is a read-only sorted map stored in a table file (see
+// FlushTo). Only its index and its range tombstones are kept in
+// memory; the data blocks are read when needed.
+type Table struct {
+	r            io.ReaderAt
+	lessThan     func(l, r interface{}) bool
+	keys, values Codec
+	length       int
+	blocks       []tableBlock
+	// ranges maps the range tombstones to true.
+	ranges *IntervalMap
+}
+
+type tableBlock struct {
+	offset, length int64
+	checksum       uint32
+	firstKey       interface{}
+}
+
+// OpenTable reads the index of the table file of the given size from
+// r. The table will use lessThan as the comparison function, and keys
+// and values to deserialize its elements; they must match the ones
+// used to write it.
+func OpenTable(r io.ReaderAt, size int64, lessThan func(l, r interface{}) bool, keys, values Codec) (*Table, error) {
+	if size < tableFooterSize {
+		return nil, ErrCorrupt
+	}
+	var footer [tableFooterSize]byte
+	if _, err := r.ReadAt(footer[:], size-tableFooterSize); err != nil {
+		return nil, err
+	}
+	indexOffset := binary.BigEndian.Uint64(footer[0:])
+	indexLength := binary.BigEndian.Uint64(footer[8:])
+	rangesLength := binary.BigEndian.Uint64(footer[16:])
+	if binary.BigEndian.Uint64(footer[40:]) != tableMagic || indexOffset+indexLength != uint64(size-tableFooterSize) || rangesLength > indexOffset {
+		return nil, ErrCorrupt
+	}
+	dataLength := indexOffset - rangesLength
+
+	t := &Table{
+		r:        r,
+		lessThan: lessThan,
+		keys:     keys,
+		values:   values,
+		length:   int(binary.BigEndian.Uint64(footer[32:])),
+		ranges:   NewIntervalMap(lessThan),
+	}
+	t.ranges.list.equal = func(l, r interface{}) bool {
+		return !lessThan(l, r) && !lessThan(r, l)
+	}
+	ranges := make([]byte, rangesLength)
+	if _, err := r.ReadAt(ranges, int64(dataLength)); err != nil {
+		return nil, err
+	}
+	if uint64(crc32.ChecksumIEEE(ranges)) != binary.BigEndian.Uint64(footer[24:]) {
+		return nil, ErrCorrupt
+	}
+	if err := t.readRanges(ranges); err != nil {
+		return nil, err
+	}
+
+	index := make([]byte, indexLength)
+	if _, err := r.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+	for len(index) > 0 {
+		var fields [4]uint64
+		for i := range fields {
+			x, n := binary.Uvarint(index)
+			if n <= 0 {
+				return nil, ErrCorrupt
+			}
+			fields[i] = x
+			index = index[n:]
+		}
+		if uint64(len(index)) < fields[3] || fields[0]+fields[1] > dataLength {
+			return nil, ErrCorrupt
+		}
+		firstKey, err := keys.Decode(index[:fields[3]])
+		if err != nil {
+			return nil, err
+		}
+		index = index[fields[3]:]
+		t.blocks = append(t.blocks, tableBlock{int64(fields[0]), int64(fields[1]), uint32(fields[2]), firstKey})
+	}
+	return t, nil
+}
+
+// readRanges decodes the range tombstones of t.
+func (t *Table) readRanges(data []byte) error {
+	field := func() (interface{}, error) {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, ErrCorrupt
+		}
+		encoded := data[n : n+int(length)]
+		data = data[n+int(length):]
+		return t.keys.Decode(encoded)
+	}
+	for len(data) > 0 {
+		from, err := field()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 || data[0] > 1 {
+			return ErrCorrupt
+		}
+		hasEnd := data[0] == 1
+		data = data[1:]
+		var to interface{}
+		if hasEnd {
+			if to, err = field(); err != nil {
+				return err
+			}
+		}
+		if from == nil || hasEnd && to == nil {
+			return ErrCorrupt
+		}
+		t.ranges.Assign(from, to, true)
+	}
+	return nil
+}
+
+// Len returns the number of entries in t, counting the tombstones but
+// not the range tombstones.
+func (t *Table) Len() int {
+	return t.length
+}
+
+// readBlock reads and decodes the i-th block of t.
+func (t *Table) readBlock(i int) (keys, values []interface{}, err error) {
+	block := t.blocks[i]
+	data := make([]byte, block.length)
+	if _, err := t.r.ReadAt(data, block.offset); err != nil {
+		return nil, nil, err
+	}
+	if crc32.ChecksumIEEE(data) != block.checksum {
+		return nil, nil, ErrCorrupt
+	}
+	field := func() ([]byte, error) {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, ErrCorrupt
+		}
+		f := data[n : n+int(length)]
+		data = data[n+int(length):]
+		return f, nil
+	}
+	for len(data) > 0 {
+		op := data[0]
+		if op != opSet && op != opDelete {
+			return nil, nil, ErrCorrupt
+		}
+		data = data[1:]
+		encodedKey, err := field()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := t.keys.Decode(encodedKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		var value interface{} = tombstone{}
+		if op == opSet {
+			encodedValue, err := field()
+			if err != nil {
+				return nil, nil, err
+			}
+			if value, err = t.values.Decode(encodedValue); err != nil {
+				return nil, nil, err
+			}
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return keys, values, nil
+}
+
+// findBlock returns the index of the last block whose first key is
+// less than or equal to key, or 0 if there is no such block.
+func (t *Table) findBlock(key interface{}) int {
+	i := sort.Search(len(t.blocks), func(i int) bool {
+		return t.lessThan(key, t.blocks[i].firstKey)
+	})
+	if i > 0 {
+		i--
+	}
+	return i
+}
+
+// lookup returns an iterator positioned on the entry for key, or nil
+// if t has no such entry.
+func (t *Table) lookup(key interface{}) (*TableIterator, error) {
+	i := t.Seek(key)
+	if i == nil {
+		return nil, nil
+	}
+	if i.err != nil {
+		return nil, i.err
+	}
+	if t.lessThan(key, i.Key()) {
+		return nil, nil
+	}
+	return i, nil
+}
+
+// Get returns the value associated with key from t (nil if the key is
+// not present in t). The second return value is true when the key is
+// present; it is false for a tombstone. err is set if reading t
+// failed.
+func (t *Table) Get(key interface{}) (value interface{}, ok bool, err error) {
+	i, err := t.lookup(key)
+	if i == nil || i.Kind() != ValueEntry {
+		return nil, false, err
+	}
+	return i.Value(), true, nil
+}
+
+// Deleted returns true if key is shadowed by a tombstone of t: either
+// its entry is a tombstone, or it has no entry and a range tombstone
+// covers it. err is set if reading t failed.
+func (t *Table) Deleted(key interface{}) (deleted bool, err error) {
+	i, err := t.lookup(key)
+	if err != nil {
+		return false, err
+	}
+	if i != nil {
+		return i.Kind() == TombstoneEntry, nil
+	}
+	_, covered := t.ranges.Lookup(key)
+	return covered, nil
+}
+
+// RangeTombstones returns an Iterator over the range tombstones of t,
+// as Intervals whose values are true.
+func (t *Table) RangeTombstones() Iterator {
+	return t.ranges.Iterator()
+}
+
+// Iterator returns an iterator that will go through all the entries of
+// t, including the tombstones.
+func (t *Table) Iterator() *TableIterator {
+	return &TableIterator{table: t, block: -1}
+}
+
+// Seek returns a bidirectional iterator starting with the first element
+// whose key is greater or equal to key; otherwise, a nil iterator is
+// returned. If reading t fails, the iterator's Err method returns the
+// error.
+func (t *Table) Seek(key interface{}) *TableIterator {
+	i := t.Iterator()
+	if !i.Seek(key) && i.err == nil {
+		return nil
+	}
+	return i
+}
+
+// SeekToFirst returns a bidirectional iterator starting from the first
+// element of t if t is not empty; otherwise, a nil iterator is
+// returned. If reading t fails, the iterator's Err method returns the
+// error.
+func (t *Table) SeekToFirst() *TableIterator {
+	i := t.Iterator()
+	if !i.Next() && i.err == nil {
+		return nil
+	}
+	return i
+}
+
+// SeekToLast returns a bidirectional iterator starting from the last
+// element of t if t is not empty; otherwise, a nil iterator is
+// returned. If reading t fails, the iterator's Err method returns the
+// error.
+func (t *Table) SeekToLast() *TableIterator {
+	i := &TableIterator{table: t, block: len(t.blocks)}
+	if !i.Previous() && i.err == nil {
+		return nil
+	}
+	return i
+}
+
+// TableIterator is an Iterator over the entries of a Table, including
+// the tombstones (see Kind), but not the range tombstones (see
+// Table.RangeTombstones). If reading the table fails, Next and
+// Previous return false, and Err returns the error.
+type TableIterator struct {
+	table *Table
+	// block is the index of the current block, and position the
+	// index of the current element in it. block is -1 before the
+	// first element.
+	block, position int
+	keys, values    []interface{}
+	err             error
+}
+
+// Err returns the first error encountered while reading the table.
+func (i *TableIterator) Err() error {
+	return i.err
+}
+
+// load makes the block with the given index current.
+func (i *TableIterator) load(block int) bool {
+	if i.err != nil {
+		return false
+	}
+	keys, values, err := i.table.readBlock(block)
+	if err != nil {
+		i.err = err
+		return false
+	}
+	i.block, i.keys, i.values = block, keys, values
+	return true
+}
+
+func (i *TableIterator) Next() bool {
+	if i.block >= 0 && i.position+1 < len(i.keys) {
+		i.position++
+		return true
+	}
+	for block := i.block + 1; block < len(i.table.blocks); block++ {
+		if !i.load(block) {
+			return false
+		}
+		if len(i.keys) > 0 {
+			i.position = 0
+			return true
+		}
+	}
+	return false
+}
+
+func (i *TableIterator) Previous() bool {
+	if i.block < 0 {
+		return false
+	}
+	if i.position > 0 {
+		i.position--
+		return true
+	}
+	for block := i.block - 1; block >= 0; block-- {
+		if !i.load(block) {
+			return false
+		}
+		if len(i.keys) > 0 {
+			i.position = len(i.keys) - 1
+			return true
+		}
+	}
+	return false
+}
+
+func (i *TableIterator) Key() interface{} {
+	if i.block < 0 || i.err != nil {
+		return nil
+	}
+	return i.keys[i.position]
+}
+
+// Value returns the value of the current entry, or nil for a
+// tombstone.
+func (i *TableIterator) Value() interface{} {
+	if i.block < 0 || i.err != nil || isTombstone(i.values[i.position]) {
+		return nil
+	}
+	return i.values[i.position]
+}
+
+// Kind returns the kind of the current entry, ValueEntry or
+// TombstoneEntry.
+func (i *TableIterator) Kind() EntryKind {
+	if i.block >= 0 && i.err == nil && isTombstone(i.values[i.position]) {
+		return TombstoneEntry
+	}
+	return ValueEntry
+}
+
+// Seek moves the iterator to the first element whose key is greater or
+// equal to key. It returns false, leaving the iterator where it was,
+// if there is no such element.
+func (i *TableIterator) Seek(key interface{}) (ok bool) {
+	t := i.table
+	if len(t.blocks) == 0 {
+		return false
+	}
+	saved := *i
+	for block := t.findBlock(key); block < len(t.blocks); block++ {
+		if !i.load(block) {
+			return false
+		}
+		position := sort.Search(len(i.keys), func(j int) bool {
+			return !t.lessThan(i.keys[j], key)
+		})
+		if position < len(i.keys) {
+			i.position = position
+			return true
+		}
+	}
+	*i = saved
+	return false
+}
+
+func (i *TableIterator) Close() {
+	i.table = nil
+	i.keys = nil
+	i.values = nil
+}
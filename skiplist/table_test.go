@@ -0,0 +1,207 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+var _ Iterator = (*TableIterator)(nil)
+
+// intCodec is a Codec for ints, much faster than GobCodec.
+type intCodec struct{}
+
+func (intCodec) Encode(v interface{}) ([]byte, error) {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutVarint(b, int64(v.(int)))], nil
+}
+
+func (intCodec) Decode(data []byte) (interface{}, error) {
+	x, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, ErrCorrupt
+	}
+	return int(x), nil
+}
+
+func flushTable(t *testing.T, s *SkipList, codec Codec) []byte {
+	var b bytes.Buffer
+	if err := s.FlushTo(&b, codec, codec); err != nil {
+		t.Fatalf("FlushTo failed: %v.", err)
+	}
+	return b.Bytes()
+}
+
+func openTable(t *testing.T, data []byte, codec Codec) *Table {
+	table, err := OpenTable(bytes.NewReader(data), int64(len(data)), intLessThan, codec, codec)
+	if err != nil {
+		t.Fatalf("OpenTable failed: %v.", err)
+	}
+	return table
+}
+
+func TestTable(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 3000; i++ {
+		s.Set(i*2, i)
+	}
+	table := openTable(t, flushTable(t, s, intCodec{}), intCodec{})
+	if table.Len() != s.Len() {
+		t.Errorf("Len should be %v, not %v.", s.Len(), table.Len())
+	}
+	if len(table.blocks) < 2 {
+		t.Errorf("The table should have several blocks, not %v.", len(table.blocks))
+	}
+
+	for i := -1; i < 6002; i++ {
+		value, ok, err := table.Get(i)
+		if err != nil {
+			t.Fatalf("Get(%v) failed: %v.", i, err)
+		}
+		expected, present := s.Get(i)
+		if ok != present || value != expected {
+			t.Fatalf("Get(%v) should return %v, %v, not %v, %v.", i, expected, present, value, ok)
+		}
+	}
+
+	i, j := table.Iterator(), s.Iterator()
+	for j.Next() {
+		if !i.Next() || i.Key() != j.Key() || i.Value() != j.Value() {
+			t.Fatalf("The table iterator should be at %v, not %v.", j.Key(), i.Key())
+		}
+	}
+	if i.Next() {
+		t.Errorf("The table iterator has too many elements.")
+	}
+	for j.Previous() {
+		if !i.Previous() || i.Key() != j.Key() {
+			t.Fatalf("The table iterator should be at %v, not %v.", j.Key(), i.Key())
+		}
+	}
+	if i.Previous() {
+		t.Errorf("The table iterator has too many elements going backward.")
+	}
+
+	if i := table.Seek(1001); i == nil || i.Key() != 1002 {
+		t.Errorf("Seek(1001) should find 1002.")
+	} else if !i.Previous() || i.Key() != 1000 {
+		t.Errorf("Previous after Seek(1001) should find 1000, not %v.", i.Key())
+	}
+	if i := table.Seek(6000); i != nil {
+		t.Errorf("Seek past the last key should return nil, not %v.", i.Key())
+	}
+	if i := table.Seek(-5); i == nil || i.Key() != 0 {
+		t.Errorf("Seek before the first key should find 0.")
+	}
+	if err := i.Err(); err != nil {
+		t.Errorf("Err should be nil, not %v.", err)
+	}
+	if i := table.SeekToFirst(); i == nil || i.Key() != 0 {
+		t.Errorf("SeekToFirst should find 0.")
+	}
+	if i := table.SeekToLast(); i == nil || i.Key() != 5998 {
+		t.Errorf("SeekToLast should find 5998.")
+	} else if !i.Previous() || i.Key() != 5996 {
+		t.Errorf("Previous after SeekToLast should find 5996, not %v.", i.Key())
+	}
+}
+
+func TestEmptyTable(t *testing.T) {
+	table := openTable(t, flushTable(t, NewIntMap(), GobCodec{}), GobCodec{})
+	if table.Len() != 0 {
+		t.Errorf("Len should be 0, not %v.", table.Len())
+	}
+	if table.Iterator().Next() {
+		t.Errorf("An empty table should have no elements.")
+	}
+	if _, ok, err := table.Get(1); ok || err != nil {
+		t.Errorf("Get on an empty table should return false, nil, not %v, %v.", ok, err)
+	}
+	if table.SeekToFirst() != nil || table.SeekToLast() != nil {
+		t.Errorf("SeekToFirst and SeekToLast should return nil for an empty table.")
+	}
+}
+
+func TestTableTombstones(t *testing.T) {
+	s := NewIntMap()
+	s.EnableTombstones()
+	for i := 0; i < 2000; i++ {
+		s.Set(i, i)
+	}
+	s.DeleteRange(100, 200)
+	s.Set(150, 150)
+	s.DeleteRange(1900, nil)
+	for i := 0; i < 100; i += 10 {
+		s.Delete(i)
+	}
+	table := openTable(t, flushTable(t, s, intCodec{}), intCodec{})
+	if expected := s.Stats().NodesPerLevel[0]; table.Len() != expected {
+		t.Errorf("Len should count the %v entries, tombstones included, not %v.", expected, table.Len())
+	}
+
+	for _, key := range []int{0, 10, 90, 100, 199, 1900, 5000} {
+		if _, ok, err := table.Get(key); ok || err != nil {
+			t.Errorf("Get(%v) should return false, nil, not %v, %v.", key, ok, err)
+		}
+		if deleted, err := table.Deleted(key); !deleted || err != nil {
+			t.Errorf("Deleted(%v) should return true, nil, not %v, %v.", key, deleted, err)
+		}
+	}
+	for _, key := range []int{1, 99, 150, 200, 1899} {
+		if value, ok, err := table.Get(key); !ok || value != key || err != nil {
+			t.Errorf("Get(%v) should return %v, true, nil, not %v, %v, %v.", key, key, value, ok, err)
+		}
+		if deleted, err := table.Deleted(key); deleted || err != nil {
+			t.Errorf("Deleted(%v) should return false, nil, not %v, %v.", key, deleted, err)
+		}
+	}
+
+	i := table.Iterator()
+	for j := s.RawIterator(); j.Next(); {
+		if j.Kind() == RangeTombstoneEntry {
+			continue
+		}
+		if !i.Next() || i.Key() != j.Key() || i.Kind() != j.Kind() || i.Value() != j.Value() {
+			t.Fatalf("The table entry should be %v (kind %v), not %v (kind %v).", j.Key(), j.Kind(), i.Key(), i.Kind())
+		}
+	}
+	if i.Next() {
+		t.Errorf("The table should have no more entries, not %v.", i.Key())
+	}
+
+	var ranges []Interval
+	for i := table.RangeTombstones(); i.Next(); {
+		ranges = append(ranges, i.Value().(Interval))
+	}
+	if expected := []Interval{{100, 200, true}, {1900, nil, true}}; !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("RangeTombstones should be %v, not %v.", expected, ranges)
+	}
+}
+
+func TestCorruptTable(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 1000; i++ {
+		s.Set(i, i)
+	}
+	data := flushTable(t, s, GobCodec{})
+
+	if _, err := OpenTable(bytes.NewReader(data[:len(data)-1]), int64(len(data)-1), intLessThan, GobCodec{}, GobCodec{}); err != ErrCorrupt {
+		t.Errorf("OpenTable of a truncated table should return ErrCorrupt, not %v.", err)
+	}
+
+	data[10] ^= 1
+	table := openTable(t, data, GobCodec{})
+	if _, _, err := table.Get(0); err != ErrCorrupt {
+		t.Errorf("Get in a corrupt block should return ErrCorrupt, not %v.", err)
+	}
+	i := table.Iterator()
+	for i.Next() {
+	}
+	if i.Err() != ErrCorrupt {
+		t.Errorf("Err should be ErrCorrupt, not %v.", i.Err())
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// When a SkipList is used as the memtable of a layered store, deleting
+// a key must leave a marker shadowing the older versions of the key in
+// the lower layers. With tombstones enabled, Delete replaces the value
+// of the key with a tombstone, and DeleteRange records a range
+// tombstone in addition to removing the nodes in the range. Nodes set
+// after a range tombstone are newer than it, so they are not covered
+// by it. Get and the iterators skip tombstones; RawIterator exposes
+// them.
+
+// tombstone is the value of the nodes recording deleted keys.
+type tombstone struct{}
+
+func isTombstone(value interface{}) bool {
+	_, ok := value.(tombstone)
+	return ok
+}
+
+// EnableTombstones makes s record tombstones for deleted keys and
+// ranges, instead of just removing them.
+func (s *SkipList) EnableTombstones() {
+	if s.ranges == nil {
+		s.ranges = NewIntervalMap(s.lessThan)
+		s.ranges.list.equal = s.equal
+	}
+}
+
+// hidden returns true if n is a tombstone.
+func (s *SkipList) hidden(n *node) bool {
+	return s.ranges != nil && isTombstone(n.value)
+}
+
+// visible returns the first node that isn't a tombstone, starting
+// from n and going forward. n may be nil.
+func (s *SkipList) visible(n *node) *node {
+	for n != nil && s.hidden(n) {
+		n = n.next()
+	}
+	return n
+}
+
+// visibleBackward returns the first node that isn't a tombstone,
+// starting from n and going backward. n may be nil.
+func (s *SkipList) visibleBackward(n *node) *node {
+	for n != nil && s.hidden(n) {
+		n = n.previous()
+	}
+	return n
+}
+
+// replace sets the value of n, keeping count of the tombstones.
+func (s *SkipList) replace(n *node, value interface{}) {
+	was, is := isTombstone(n.value), isTombstone(value)
+	if was {
+		s.tombstones--
+	}
+	if is {
+		s.tombstones++
+	}
+	if s.bloom != nil && was != is {
+		if is {
+			s.bloom.stale++
+		} else {
+			s.bloom.maybeRebuild(s)
+			s.bloom.add(n.key)
+		}
+	}
+	n.value = value
+}
+
+// DeleteRange removes all the elements of s whose keys are greater or
+// equal than from, but less than to. If to is nil, it removes all the
+// elements from from on. It returns the number of removed elements.
+// If tombstones are enabled, it also records a range tombstone for
+// [from, to).
+func (s *SkipList) DeleteRange(from, to interface{}) (removed int) {
+	if from == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	removed = s.deleteRange(from, to)
+	if s.ranges != nil {
+		s.ranges.Assign(from, to, true)
+	}
+	return removed
+}
+
+// Deleted returns true if key is shadowed by a tombstone: either its
+// node is a tombstone, or it has no node and a range tombstone covers
+// it. It always returns false if tombstones are not enabled.
+func (s *SkipList) Deleted(key interface{}) bool {
+	if s.ranges == nil {
+		return false
+	}
+	candidate := s.search(nil, key)
+	if candidate != nil && s.keysEqual(candidate.key, key) {
+		return isTombstone(candidate.value)
+	}
+	_, covered := s.ranges.Lookup(key)
+	return covered
+}
+
+// An EntryKind tells what the current entry of a RawIterator is.
+type EntryKind int
+
+const (
+	// ValueEntry is a key with a value.
+	ValueEntry EntryKind = iota
+	// TombstoneEntry is a deleted key.
+	TombstoneEntry
+	// RangeTombstoneEntry is a deleted range of keys.
+	RangeTombstoneEntry
+)
+
+// A RawIterator goes through all the entries of a SkipList, including
+// the tombstones and the range tombstones, in key order. A range
+// tombstone comes before the nodes with the same key. Like the other
+// iterators, it stays on the current entry if it can't move. It
+// implements Iterator.
+type RawIterator struct {
+	list *SkipList
+	// ranges holds the range tombstones, keyed by their start. It
+	// is nil if tombstones are not enabled.
+	ranges *SkipList
+	// current is the current entry, a node of ranges if inRange is
+	// set, and of list otherwise. It is nil before the first call
+	// to Next.
+	current *node
+	inRange bool
+	// nextNode and nextRange are the first nodes of list and ranges
+	// after the current position.
+	nextNode, nextRange *node
+}
+
+// RawIterator returns a RawIterator over s, positioned before its
+// first entry.
+func (s *SkipList) RawIterator() *RawIterator {
+	i := &RawIterator{list: s}
+	if s.ranges != nil {
+		i.ranges = s.ranges.list
+	}
+	i.rewind(s.header.next(), i.firstRange(nil))
+	return i
+}
+
+// firstRange returns the first range tombstone starting at key or
+// after it (the first one if key is nil).
+func (i *RawIterator) firstRange(key interface{}) *node {
+	switch {
+	case i.ranges == nil:
+		return nil
+	case key == nil:
+		return i.ranges.header.next()
+	}
+	return i.ranges.getPath(i.ranges.header, nil, key)
+}
+
+// rewind positions i just before the given nodes.
+func (i *RawIterator) rewind(nextNode, nextRange *node) {
+	i.current, i.inRange = nil, false
+	i.nextNode, i.nextRange = nextNode, nextRange
+}
+
+// Next moves to the next entry. It returns false if there is none.
+func (i *RawIterator) Next() bool {
+	n, r := i.nextNode, i.nextRange
+	switch {
+	case r != nil && (n == nil || !i.list.lessThan(n.key, r.key)):
+		i.current, i.inRange = r, true
+		i.nextRange = r.next()
+	case n != nil:
+		i.current, i.inRange = n, false
+		i.nextNode = n.next()
+	default:
+		return false
+	}
+	return true
+}
+
+// Previous moves to the previous entry. It returns false if there is
+// none.
+func (i *RawIterator) Previous() bool {
+	n, r := i.previousIn(i.list, i.nextNode, false), i.previousIn(i.ranges, i.nextRange, true)
+	if n == nil && r == nil {
+		return false
+	}
+	if i.current != nil {
+		if i.inRange {
+			i.nextRange = i.current
+		} else {
+			i.nextNode = i.current
+		}
+	}
+	if r != nil && (n == nil || i.list.lessThan(n.key, r.key)) {
+		i.current, i.inRange = r, true
+	} else {
+		i.current, i.inRange = n, false
+	}
+	return true
+}
+
+// previousIn returns the last node of list before the current
+// position, given the first one after it. inRange tells whether list
+// holds the range tombstones.
+func (i *RawIterator) previousIn(list *SkipList, next *node, inRange bool) *node {
+	switch {
+	case list == nil:
+		return nil
+	case i.current != nil && i.inRange == inRange:
+		return i.current.previous()
+	case next != nil:
+		return next.previous()
+	}
+	return list.footer
+}
+
+// Seek moves to the first entry whose key is greater or equal to key.
+// If there is none, it returns false and stays on the current entry.
+func (i *RawIterator) Seek(key interface{}) bool {
+	saved := *i
+	i.rewind(i.list.getPath(i.list.header, nil, key), i.firstRange(key))
+	if !i.Next() {
+		*i = saved
+		return false
+	}
+	return true
+}
+
+// SeekToFirst moves to the first entry. It returns false if there is
+// none.
+func (i *RawIterator) SeekToFirst() bool {
+	i.rewind(i.list.header.next(), i.firstRange(nil))
+	return i.Next()
+}
+
+// SeekToLast moves to the last entry. It returns false if there is
+// none.
+func (i *RawIterator) SeekToLast() bool {
+	i.rewind(nil, nil)
+	return i.Previous()
+}
+
+// Close releases the resources associated with the iterator.
+func (i *RawIterator) Close() {
+	i.list, i.ranges = nil, nil
+	i.current, i.nextNode, i.nextRange = nil, nil, nil
+}
+
+// Kind returns the kind of the current entry.
+func (i *RawIterator) Kind() EntryKind {
+	switch {
+	case i.current == nil || !i.inRange && !isTombstone(i.current.value):
+		return ValueEntry
+	case i.inRange:
+		return RangeTombstoneEntry
+	}
+	return TombstoneEntry
+}
+
+// Key returns the key of the current entry, or the start of the range
+// for a range tombstone.
+func (i *RawIterator) Key() interface{} {
+	if i.current == nil {
+		return nil
+	}
+	return i.current.key
+}
+
+// End returns the end (exclusive) of the current range tombstone. It
+// returns nil for other entries, and for range tombstones without an
+// end.
+func (i *RawIterator) End() interface{} {
+	if i.current == nil || !i.inRange {
+		return nil
+	}
+	return i.current.value.(*Interval).To
+}
+
+// Value returns the value of the current entry, or nil for tombstones.
+func (i *RawIterator) Value() interface{} {
+	if i.current == nil || i.Kind() != ValueEntry {
+		return nil
+	}
+	return i.current.value
+}
@@ -0,0 +1,275 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTombstones(t *testing.T) {
+	s := NewIntMap()
+	s.EnableTombstones()
+	for i := 0; i < 10; i++ {
+		s.Set(i, i)
+	}
+
+	if value, ok := s.Delete(3); !ok || value != 3 {
+		t.Errorf("Delete(3) should return 3, true, not %v, %v.", value, ok)
+	}
+	if _, ok := s.Delete(3); ok {
+		t.Errorf("Deleting 3 twice should fail.")
+	}
+	if _, ok := s.Delete(20); ok {
+		t.Errorf("Deleting a missing key should fail.")
+	}
+	if _, ok := s.Get(3); ok {
+		t.Errorf("3 should be hidden.")
+	}
+	if !s.Deleted(3) || !s.Deleted(20) || s.Deleted(4) || s.Deleted(30) {
+		t.Errorf("3 and 20 should be deleted, 4 and 30 should not.")
+	}
+	if s.Len() != 9 {
+		t.Errorf("Len should be 9, not %v.", s.Len())
+	}
+	if key, _, ok := s.GetGreaterOrEqual(3); !ok || key != 4 {
+		t.Errorf("GetGreaterOrEqual(3) should be 4, not %v.", key)
+	}
+	if key, _, ok := s.GetLessOrEqual(3); !ok || key != 2 {
+		t.Errorf("GetLessOrEqual(3) should be 2, not %v.", key)
+	}
+
+	if removed := s.DeleteRange(5, 8); removed != 3 {
+		t.Errorf("DeleteRange(5, 8) should remove 3 elements, not %v.", removed)
+	}
+	for _, key := range []int{5, 6, 7} {
+		if !s.Deleted(key) {
+			t.Errorf("%v should be covered by the range tombstone.", key)
+		}
+	}
+	s.Set(6, 60)
+	s.check(t, 6, 60)
+	if s.Deleted(6) {
+		t.Errorf("6 was set after the range tombstone, so it should not be deleted.")
+	}
+	s.Set(3, 30)
+	s.check(t, 3, 30)
+
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Delete(9)
+	var keys []interface{}
+	for i := s.Iterator(); i.Next(); {
+		keys = append(keys, i.Key())
+	}
+	if fmt.Sprint(keys) != "[0 1 2 3 4 6 8]" {
+		t.Errorf("Iterator should yield [0 1 2 3 4 6 8], not %v.", keys)
+	}
+	keys = nil
+	for i := s.SeekToLast(); i != nil; {
+		keys = append(keys, i.Key())
+		if !i.Previous() {
+			break
+		}
+	}
+	if fmt.Sprint(keys) != "[8 6 4 3 2 1 0]" {
+		t.Errorf("Going backward should yield [8 6 4 3 2 1 0], not %v.", keys)
+	}
+	if i := s.Seek(9); i != nil {
+		t.Errorf("Seek(9) should return nil, not an iterator at %v.", i.Key())
+	}
+
+	if stats := s.Stats(); stats.Len != 7 || stats.Tombstones != 2 || stats.NodesPerLevel[0] != 9 {
+		t.Errorf("Stats should count 7 elements and 2 tombstones in 9 nodes, not %+v.", stats)
+	}
+}
+
+func TestRawIterator(t *testing.T) {
+	s := NewIntMap()
+	s.EnableTombstones()
+	for i := 0; i < 6; i++ {
+		s.Set(i, i)
+	}
+	s.Delete(1)
+	s.DeleteRange(3, 5)
+	s.Set(3, 30)
+
+	var entries []string
+	for i := s.RawIterator(); i.Next(); {
+		switch i.Kind() {
+		case ValueEntry:
+			entries = append(entries, fmt.Sprintf("%v=%v", i.Key(), i.Value()))
+		case TombstoneEntry:
+			entries = append(entries, fmt.Sprintf("-%v", i.Key()))
+		case RangeTombstoneEntry:
+			entries = append(entries, fmt.Sprintf("-[%v,%v)", i.Key(), i.End()))
+		}
+	}
+	if expected := "[0=0 -1 2=2 -[3,5) 3=30 5=5]"; fmt.Sprint(entries) != expected {
+		t.Errorf("RawIterator should yield %v, not %v.", expected, entries)
+	}
+}
+
+// rawEntry describes the current entry of i.
+func rawEntry(i *RawIterator) string {
+	switch i.Kind() {
+	case TombstoneEntry:
+		return fmt.Sprintf("-%v", i.Key())
+	case RangeTombstoneEntry:
+		return fmt.Sprintf("-[%v,%v)", i.Key(), i.End())
+	}
+	return fmt.Sprintf("%v=%v", i.Key(), i.Value())
+}
+
+func TestRawIteratorBackward(t *testing.T) {
+	s := NewIntMap()
+	s.EnableTombstones()
+	for i := 0; i < 6; i++ {
+		s.Set(i, i)
+	}
+	s.Delete(1)
+	s.DeleteRange(3, 5)
+	s.Set(3, 30)
+	s.DeleteRange(7, 9)
+
+	expected := []string{"0=0", "-1", "2=2", "-[3,5)", "3=30", "5=5", "-[7,9)"}
+	i := s.RawIterator()
+	if i.Previous() {
+		t.Errorf("Previous should return false before the first entry.")
+	}
+	if !i.SeekToLast() {
+		t.Fatalf("SeekToLast should find an entry.")
+	}
+	for j := len(expected) - 1; j >= 0; j-- {
+		if rawEntry(i) != expected[j] {
+			t.Errorf("Entry %v should be %v, not %v.", j, expected[j], rawEntry(i))
+		}
+		if ok := i.Previous(); ok != (j > 0) {
+			t.Errorf("Previous at entry %v should return %v, not %v.", j, j > 0, ok)
+		}
+	}
+	for j := 1; j < len(expected); j++ {
+		if !i.Next() || rawEntry(i) != expected[j] {
+			t.Errorf("Entry %v should be %v, not %v.", j, expected[j], rawEntry(i))
+		}
+	}
+	if i.Next() || rawEntry(i) != "-[7,9)" {
+		t.Errorf("Next should stay on the last entry, not move to %v.", rawEntry(i))
+	}
+
+	// Change direction in the middle.
+	if !i.Seek(3) || rawEntry(i) != "-[3,5)" {
+		t.Errorf("Seek(3) should move to -[3,5), not %v.", rawEntry(i))
+	}
+	if !i.Next() || rawEntry(i) != "3=30" || !i.Previous() || rawEntry(i) != "-[3,5)" || !i.Previous() || rawEntry(i) != "2=2" {
+		t.Errorf("Moving around -[3,5) should work, not end on %v.", rawEntry(i))
+	}
+	if !i.Seek(4) || rawEntry(i) != "5=5" {
+		t.Errorf("Seek(4) should move to 5=5, not %v.", rawEntry(i))
+	}
+	if !i.Seek(6) || rawEntry(i) != "-[7,9)" {
+		t.Errorf("Seek(6) should move to -[7,9), not %v.", rawEntry(i))
+	}
+	if i.Seek(10) || rawEntry(i) != "-[7,9)" {
+		t.Errorf("Seek(10) should fail and stay on -[7,9), not move to %v.", rawEntry(i))
+	}
+	if !i.SeekToFirst() || rawEntry(i) != "0=0" {
+		t.Errorf("SeekToFirst should move to 0=0, not %v.", rawEntry(i))
+	}
+
+	// Without tombstones, there are only values.
+	s = NewIntMap()
+	s.Set(1, 1)
+	s.Set(2, 2)
+	i = s.RawIterator()
+	if !i.SeekToLast() || rawEntry(i) != "2=2" || !i.Previous() || rawEntry(i) != "1=1" || i.Previous() {
+		t.Errorf("RawIterator should go backward over a list without tombstones, not stop on %v.", rawEntry(i))
+	}
+}
+
+func TestTombstonesDeletePrefixUnbounded(t *testing.T) {
+	s := NewStringMap()
+	s.EnableTombstones()
+	for _, key := range []string{"a", "b", "\xff", "\xff\x01", "\xff\xff"} {
+		s.Set(key, key)
+	}
+
+	if removed := s.DeletePrefix("\xff"); removed != 3 {
+		t.Errorf("DeletePrefix(\"\\xff\") should remove 3 elements, not %v.", removed)
+	}
+	if !s.Deleted("\xff\xff\xff") || s.Deleted("b") {
+		t.Errorf("The range tombstone for \"\\xff\" should cover \"\\xff\\xff\\xff\", but not \"b\".")
+	}
+	i := s.RawIterator()
+	for i.Next() && i.Kind() != RangeTombstoneEntry {
+	}
+	if i.Kind() != RangeTombstoneEntry || i.Key() != "\xff" || i.End() != nil {
+		t.Errorf("RawIterator should expose an unbounded range tombstone at \"\\xff\", not %v, %v.", i.Key(), i.End())
+	}
+
+	if removed := s.DeletePrefix(""); removed != 2 || s.Len() != 0 {
+		t.Errorf("DeletePrefix(\"\") should remove 2 elements, not %v (Len %v).", removed, s.Len())
+	}
+	for _, key := range []string{"", "a", "zzz", "\xff"} {
+		if !s.Deleted(key) {
+			t.Errorf("%q should be deleted.", key)
+		}
+	}
+	s.Set("c", "c")
+	if value, ok := s.Get("c"); !ok || value != "c" {
+		t.Errorf("c was set after the range tombstone, so it should be c, not %v.", value)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTombstonesUpdate(t *testing.T) {
+	s := NewIntMap()
+	s.EnableTombstones()
+	s.Set(1, 1)
+	s.Delete(1)
+
+	if _, loaded := s.GetOrSet(1, 10); loaded {
+		t.Errorf("GetOrSet should not load a deleted key.")
+	}
+	s.check(t, 1, 10)
+	if !s.CompareAndDelete(1, 10) {
+		t.Errorf("CompareAndDelete(1, 10) should succeed.")
+	}
+	if !s.Deleted(1) {
+		t.Errorf("1 should be deleted.")
+	}
+
+	if inserted, updated := s.SetMany([]Pair{{1, 1}, {2, 2}}); inserted != 2 || updated != 0 {
+		t.Errorf("SetMany should insert 2 and update 0, not %v and %v.", inserted, updated)
+	}
+	if deleted := s.DeleteMany([]interface{}{1, 2, 3}); deleted != 2 {
+		t.Errorf("DeleteMany should delete 2 keys, not %v.", deleted)
+	}
+
+	s.Set(5, 5)
+	b := new(Batch)
+	b.Delete(5)
+	b.Set(6, 6)
+	b.Set("x", 7)
+	if err := s.Apply(b); err == nil {
+		t.Fatalf("Apply with a bad key should fail.")
+	}
+	s.check(t, 5, 5)
+	if _, ok := s.Get(6); ok {
+		t.Errorf("The failed batch should not set 6.")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len should be 1, not %v.", s.Len())
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Freeze().Len() != 1 {
+		t.Errorf("The frozen map should not contain the tombstones.")
+	}
+}
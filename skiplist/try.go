@@ -0,0 +1,88 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"errors"
+	"runtime"
+)
+
+var (
+	// ErrNilKey is returned when a nil key is passed to one of the
+	// Try methods.
+	ErrNilKey = errors.New("goskiplist: nil keys are not supported")
+	// ErrKeyType is returned when the comparison function fails a
+	// type assertion, which usually means that the key has a type
+	// the skip list doesn't support.
+	ErrKeyType = errors.New("goskiplist: key of unsupported type")
+	// ErrComparator is returned when the comparison function panics
+	// for any other reason.
+	ErrComparator = errors.New("goskiplist: comparison function panicked")
+)
+
+// recoverComparator turns a panic in the comparison function into an
+// error stored in err. It must be deferred.
+func recoverComparator(err *error) {
+	if r := recover(); r != nil {
+		*err = comparatorError(r)
+	}
+}
+
+// comparatorError returns the error corresponding to a value
+// recovered from a panic in the comparison function.
+func comparatorError(r interface{}) error {
+	if _, ok := r.(*runtime.TypeAssertionError); ok {
+		return ErrKeyType
+	}
+	return ErrComparator
+}
+
+// checkKey calls the comparison function of s on key alone, so that a
+// key of the wrong type is detected even if s is empty. It returns
+// ErrKeyType or ErrComparator if the comparison function panics.
+func (s *SkipList) checkKey(key interface{}) (err error) {
+	defer recoverComparator(&err)
+	s.lessThan(key, key)
+	return nil
+}
+
+// TrySet is like Set, but instead of panicking it returns ErrNilKey
+// for nil keys, and ErrKeyType or ErrComparator if the comparison
+// function panics. If an error is returned, s is left unmodified.
+//
+// Note that the comparison function is only called when s isn't
+// empty, so a key of the wrong type may be accepted into an empty
+// list.
+func (s *SkipList) TrySet(key, value interface{}) (err error) {
+	if key == nil {
+		return ErrNilKey
+	}
+	defer recoverComparator(&err)
+	s.Set(key, value)
+	return nil
+}
+
+// TryGet is like Get, but it returns ErrNilKey for nil keys, and
+// ErrKeyType or ErrComparator if the comparison function panics.
+func (s *SkipList) TryGet(key interface{}) (value interface{}, ok bool, err error) {
+	if key == nil {
+		return nil, false, ErrNilKey
+	}
+	defer recoverComparator(&err)
+	value, ok = s.Get(key)
+	return value, ok, nil
+}
+
+// TryDelete is like Delete, but instead of panicking it returns
+// ErrNilKey for nil keys, and ErrKeyType or ErrComparator if the
+// comparison function panics. If an error is returned, s is left
+// unmodified.
+func (s *SkipList) TryDelete(key interface{}) (value interface{}, ok bool, err error) {
+	if key == nil {
+		return nil, false, ErrNilKey
+	}
+	defer recoverComparator(&err)
+	value, ok = s.Delete(key)
+	return value, ok, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"testing"
+)
+
+func TestTrySet(t *testing.T) {
+	s := NewIntMap()
+	if err := s.TrySet(nil, 0); err != ErrNilKey {
+		t.Errorf("TrySet(nil, 0) should return ErrNilKey, not %v.", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := s.TrySet(i, i); err != nil {
+			t.Fatalf("TrySet(%d, %d) failed: %v.", i, i, err)
+		}
+	}
+
+	if err := s.TrySet("a", 0); err != ErrKeyType {
+		t.Errorf("TrySet(\"a\", 0) should return ErrKeyType, not %v.", err)
+	}
+	if s.Len() != 100 {
+		t.Errorf("A failed TrySet changed Len to %v.", s.Len())
+	}
+	if err := s.Verify(); err != nil {
+		t.Errorf("A failed TrySet broke the list: %v.", err)
+	}
+	s.check(t, 50, 50)
+}
+
+func TestTryGet(t *testing.T) {
+	s := NewIntMap()
+	s.Set(1, 1)
+
+	if _, _, err := s.TryGet(nil); err != ErrNilKey {
+		t.Errorf("TryGet(nil) should return ErrNilKey, not %v.", err)
+	}
+	if _, _, err := s.TryGet("a"); err != ErrKeyType {
+		t.Errorf("TryGet(\"a\") should return ErrKeyType, not %v.", err)
+	}
+	if v, ok, err := s.TryGet(1); v != 1 || !ok || err != nil {
+		t.Errorf("TryGet(1) should return 1, true, nil, not %v, %v, %v.", v, ok, err)
+	}
+	if v, ok, err := s.TryGet(2); v != nil || ok || err != nil {
+		t.Errorf("TryGet(2) should return nil, false, nil, not %v, %v, %v.", v, ok, err)
+	}
+}
+
+func TestTryDelete(t *testing.T) {
+	s := NewCustomMap(func(l, r interface{}) bool {
+		if l == 13 || r == 13 {
+			panic("unlucky")
+		}
+		return l.(int) < r.(int)
+	})
+	for i := 0; i < 10; i++ {
+		s.Set(i, i)
+	}
+
+	if _, _, err := s.TryDelete(nil); err != ErrNilKey {
+		t.Errorf("TryDelete(nil) should return ErrNilKey, not %v.", err)
+	}
+	if _, _, err := s.TryDelete(13); err != ErrComparator {
+		t.Errorf("TryDelete(13) should return ErrComparator, not %v.", err)
+	}
+	if s.Len() != 10 {
+		t.Errorf("A failed TryDelete changed Len to %v.", s.Len())
+	}
+	if v, ok, err := s.TryDelete(5); v != 5 || !ok || err != nil {
+		t.Errorf("TryDelete(5) should return 5, true, nil, not %v, %v, %v.", v, ok, err)
+	}
+	if err := s.Verify(); err != nil {
+		t.Errorf("Verify failed: %v.", err)
+	}
+}
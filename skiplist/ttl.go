@@ -0,0 +1,143 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"time"
+)
+
+// A TTLMap is a map whose entries may expire. Expired entries are
+// hidden by Get, which removes them lazily, and ExpireNow removes all
+// the entries that are due. A second skip list, ordered by deadline,
+// makes ExpireNow take O(log n) time per expired entry.
+type TTLMap struct {
+	// entries maps keys to *ttlEntry.
+	entries *SkipList
+	// deadlines maps *expiryKey to the keys of entries.
+	deadlines *SkipList
+	now       func() time.Time
+	// sequence distinguishes entries with the same deadline.
+	sequence uint64
+}
+
+type ttlEntry struct {
+	value interface{}
+	// expiry is nil if the entry doesn't expire.
+	expiry *expiryKey
+}
+
+type expiryKey struct {
+	deadline time.Time
+	sequence uint64
+}
+
+func expiryLessThan(l, r interface{}) bool {
+	a, b := l.(*expiryKey), r.(*expiryKey)
+	if !a.deadline.Equal(b.deadline) {
+		return a.deadline.Before(b.deadline)
+	}
+	return a.sequence < b.sequence
+}
+
+// NewTTLMap returns a new TTLMap that will use lessThan as the
+// comparison function for keys, and now to tell the current time. If
+// now is nil, time.Now is used.
+func NewTTLMap(lessThan func(l, r interface{}) bool, now func() time.Time) *TTLMap {
+	if now == nil {
+		now = time.Now
+	}
+	return &TTLMap{
+		entries:   NewCustomMap(lessThan),
+		deadlines: NewCustomMap(expiryLessThan),
+		now:       now,
+	}
+}
+
+// Len returns the number of entries in m, including the expired
+// entries that haven't been removed yet.
+func (m *TTLMap) Len() int {
+	return m.entries.Len()
+}
+
+// expired returns true if e has reached its deadline.
+func (m *TTLMap) expired(e *ttlEntry, now time.Time) bool {
+	return e.expiry != nil && !now.Before(e.expiry.deadline)
+}
+
+// Set sets the value associated with key in m. The entry doesn't
+// expire.
+func (m *TTLMap) Set(key, value interface{}) {
+	m.set(key, &ttlEntry{value: value})
+}
+
+// SetWithTTL sets the value associated with key in m. The entry
+// expires after ttl.
+func (m *TTLMap) SetWithTTL(key, value interface{}, ttl time.Duration) {
+	m.sequence++
+	expiry := &expiryKey{m.now().Add(ttl), m.sequence}
+	m.set(key, &ttlEntry{value: value, expiry: expiry})
+}
+
+func (m *TTLMap) set(key interface{}, e *ttlEntry) {
+	if key == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	if old, existed := m.entries.set(key, e); existed {
+		if expiry := old.(*ttlEntry).expiry; expiry != nil {
+			m.deadlines.delete(expiry)
+		}
+	}
+	if e.expiry != nil {
+		m.deadlines.set(e.expiry, key)
+	}
+}
+
+// Get returns the value associated with key from m (nil if the key is
+// not present in m or has expired). The second return value is true
+// when the key is present. If the entry has expired, Get removes it.
+func (m *TTLMap) Get(key interface{}) (value interface{}, ok bool) {
+	e, ok := m.entries.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := e.(*ttlEntry)
+	if m.expired(entry, m.now()) {
+		m.Delete(key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Delete removes the entry with the given key. It returns the old
+// value and whether the entry was present and not expired.
+func (m *TTLMap) Delete(key interface{}) (value interface{}, ok bool) {
+	e, ok := m.entries.Delete(key)
+	if !ok {
+		return nil, false
+	}
+	entry := e.(*ttlEntry)
+	if entry.expiry != nil {
+		m.deadlines.delete(entry.expiry)
+	}
+	if m.expired(entry, m.now()) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// ExpireNow removes all the entries that have expired, and returns
+// their number.
+func (m *TTLMap) ExpireNow() (expired int) {
+	now := m.now()
+	for first := m.deadlines.header.next(); first != nil; first = m.deadlines.header.next() {
+		expiry := first.key.(*expiryKey)
+		if now.Before(expiry.deadline) {
+			break
+		}
+		m.deadlines.delete(expiry)
+		m.entries.delete(first.value)
+		expired++
+	}
+	return expired
+}
@@ -0,0 +1,85 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestTTLMap(t *testing.T) {
+	clock := &fakeClock{time.Unix(1000, 0)}
+	m := NewTTLMap(intLessThan, clock.Now)
+	m.SetWithTTL(1, "one", time.Second)
+	m.SetWithTTL(2, "two", 2*time.Second)
+	m.SetWithTTL(3, "three", 3*time.Second)
+	m.Set(4, "four")
+
+	if value, ok := m.Get(1); !ok || value != "one" {
+		t.Errorf("Get(1) should return one, not %v, %v.", value, ok)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if value, ok := m.Get(1); ok {
+		t.Errorf("Get(1) should hide the expired entry, not return %v.", value)
+	}
+	if m.Len() != 3 {
+		t.Errorf("Get should have removed the expired entry (Len %v).", m.Len())
+	}
+
+	// Setting again replaces the deadline.
+	m.SetWithTTL(2, "two", 10*time.Second)
+	clock.now = clock.now.Add(5 * time.Second)
+	if expired := m.ExpireNow(); expired != 1 {
+		t.Errorf("ExpireNow should have removed 1 entry, not %v.", expired)
+	}
+	if _, ok := m.Get(3); ok {
+		t.Errorf("3 should have expired.")
+	}
+	if value, ok := m.Get(2); !ok || value != "two" {
+		t.Errorf("Get(2) should return two, not %v, %v.", value, ok)
+	}
+	if value, ok := m.Get(4); !ok || value != "four" {
+		t.Errorf("Get(4) should return four, not %v, %v.", value, ok)
+	}
+
+	if value, ok := m.Delete(2); !ok || value != "two" {
+		t.Errorf("Delete(2) should return two, not %v, %v.", value, ok)
+	}
+	clock.now = clock.now.Add(time.Hour)
+	if expired := m.ExpireNow(); expired != 0 {
+		t.Errorf("ExpireNow shouldn't find deleted entries (%v).", expired)
+	}
+	if m.Len() != 1 || m.deadlines.Len() != 0 {
+		t.Errorf("Only 4 should be left, not %v entries and %v deadlines.", m.Len(), m.deadlines.Len())
+	}
+}
+
+func TestTTLMapExpireNow(t *testing.T) {
+	clock := &fakeClock{time.Unix(0, 0)}
+	m := NewTTLMap(intLessThan, clock.Now)
+	for i := 0; i < 100; i++ {
+		// Many entries share the same deadline.
+		m.SetWithTTL(i, i, time.Duration(i/10)*time.Second)
+	}
+	clock.now = clock.now.Add(5 * time.Second)
+	if expired := m.ExpireNow(); expired != 60 {
+		t.Errorf("ExpireNow should have removed 60 entries, not %v.", expired)
+	}
+	if m.Len() != 40 {
+		t.Errorf("Len should be 40, not %v.", m.Len())
+	}
+	if _, ok := m.Get(60); !ok {
+		t.Errorf("60 shouldn't have expired yet.")
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"errors"
+)
+
+// ErrTxnDone is returned when committing a transaction that was
+// already committed or rolled back.
+var ErrTxnDone = errors.New("goskiplist: transaction already committed or rolled back")
+
+// A Batch records Set and Delete operations, which can then be applied
+// to a SkipList all at once (see SkipList.Apply). The zero value is an
+// empty batch ready to use.
+type Batch struct {
+	ops []batchOp
+}
+
+type batchOp struct {
+	key, value interface{}
+	delete     bool
+}
+
+// Set records setting the value associated with key.
+func (b *Batch) Set(key, value interface{}) {
+	b.ops = append(b.ops, batchOp{key: key, value: value})
+}
+
+// Delete records removing key.
+func (b *Batch) Delete(key interface{}) {
+	b.ops = append(b.ops, batchOp{key: key, delete: true})
+}
+
+// Len returns the number of operations recorded in b.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset removes all the operations recorded in b.
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Apply applies all the operations recorded in b to s, in the order
+// they were recorded. Either all of them succeed, or s is left
+// unmodified: if any key is nil, Apply returns ErrNilKey without
+// doing anything, and if the comparison function panics, the
+// operations that were already applied are rolled back and Apply
+// returns ErrKeyType or ErrComparator.
+//
+// As with other methods of SkipList, Apply should not be called
+// concurrently with any other method.
+func (s *SkipList) Apply(b *Batch) (err error) {
+	for _, op := range b.ops {
+		if op.key == nil {
+			return ErrNilKey
+		}
+	}
+
+	// undo holds the operations that restore the previous state of
+	// s, in the order in which they have to be applied.
+	var undo []batchOp
+	defer func() {
+		if r := recover(); r != nil {
+			for i := len(undo) - 1; i >= 0; i-- {
+				if op := undo[i]; op.delete {
+					s.unlink(op.key)
+				} else {
+					s.set(op.key, op.value)
+				}
+			}
+			err = comparatorError(r)
+		}
+	}()
+
+	for _, op := range b.ops {
+		value := op.value
+		if op.delete {
+			if s.ranges == nil {
+				if old, ok := s.delete(op.key); ok {
+					undo = append(undo, batchOp{key: op.key, value: old})
+				}
+				continue
+			}
+			// The tombstone may replace another tombstone, which
+			// has to be restored as well.
+			value = tombstone{}
+		}
+		if old, existed := s.set(op.key, value); existed {
+			undo = append(undo, batchOp{key: op.key, value: old})
+		} else {
+			undo = append(undo, batchOp{key: op.key, delete: true})
+		}
+	}
+	return nil
+}
+
+// A Txn is a transaction on a SkipList. The Set and Delete operations
+// made through a Txn are visible to its Get, but they are applied to
+// the underlying SkipList only when the transaction is committed,
+// all at once. Modifications made to the SkipList directly while the
+// transaction is open are visible through the Txn, unless they are
+// shadowed by its own writes.
+type Txn struct {
+	list *SkipList
+	// writes maps keys to *txnWrite.
+	writes *SkipList
+}
+
+type txnWrite struct {
+	value   interface{}
+	deleted bool
+}
+
+// Begin starts a new transaction on s.
+func (s *SkipList) Begin() *Txn {
+	writes := NewCustomMap(s.lessThan)
+	writes.equal = s.equal
+	return &Txn{list: s, writes: writes}
+}
+
+// Get returns the value associated with key, as seen by the
+// transaction. The second return value is true when the key is
+// present.
+func (t *Txn) Get(key interface{}) (value interface{}, ok bool) {
+	if w, ok := t.writes.Get(key); ok {
+		write := w.(*txnWrite)
+		if write.deleted {
+			return nil, false
+		}
+		return write.value, true
+	}
+	return t.list.Get(key)
+}
+
+// Set sets the value associated with key in the transaction.
+func (t *Txn) Set(key, value interface{}) {
+	t.writes.Set(key, &txnWrite{value: value})
+}
+
+// Delete removes key in the transaction. It returns the old value and
+// whether the key was present, as seen by the transaction.
+func (t *Txn) Delete(key interface{}) (value interface{}, ok bool) {
+	value, ok = t.Get(key)
+	t.writes.Set(key, &txnWrite{deleted: true})
+	return value, ok
+}
+
+// Commit applies all the writes of the transaction to the underlying
+// SkipList, in key order. It fails (leaving the SkipList unmodified)
+// under the same conditions as SkipList.Apply. After Commit, the
+// transaction cannot be used anymore.
+func (t *Txn) Commit() error {
+	if t.list == nil {
+		return ErrTxnDone
+	}
+
+	var b Batch
+	for i := t.writes.Iterator(); i.Next(); {
+		if write := i.Value().(*txnWrite); write.deleted {
+			b.Delete(i.Key())
+		} else {
+			b.Set(i.Key(), write.value)
+		}
+	}
+	err := t.list.Apply(&b)
+	t.list, t.writes = nil, nil
+	return err
+}
+
+// Rollback abandons all the writes of the transaction. After
+// Rollback, the transaction cannot be used anymore.
+func (t *Txn) Rollback() {
+	t.list, t.writes = nil, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 10; i++ {
+		s.Set(i, i)
+	}
+
+	var b Batch
+	b.Set(10, 10)
+	b.Set(3, 30)
+	b.Delete(4)
+	b.Delete(100)
+	b.Set(4, 40)
+	if b.Len() != 5 {
+		t.Errorf("Len should be 5, not %v.", b.Len())
+	}
+	if err := s.Apply(&b); err != nil {
+		t.Fatalf("Apply failed: %v.", err)
+	}
+	s.check(t, 10, 10)
+	s.check(t, 3, 30)
+	s.check(t, 4, 40)
+	if s.Len() != 11 {
+		t.Errorf("Len should be 11, not %v.", s.Len())
+	}
+
+	b.Reset()
+	b.Set(20, 20)
+	b.Set(nil, 0)
+	if err := s.Apply(&b); err != ErrNilKey {
+		t.Errorf("Apply should have returned ErrNilKey, not %v.", err)
+	}
+	if _, ok := s.Get(20); ok {
+		t.Errorf("A failed Apply shouldn't modify the list.")
+	}
+
+	b.Reset()
+	b.Set(20, 20)
+	b.Set(3, 300)
+	b.Delete(5)
+	b.Delete(10)
+	b.Set("not an int", 0)
+	if err := s.Apply(&b); err != ErrKeyType {
+		t.Errorf("Apply should have returned ErrKeyType, not %v.", err)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v.", err)
+	}
+	if _, ok := s.Get(20); ok {
+		t.Errorf("A failed Apply should have removed the key it inserted.")
+	}
+	s.check(t, 3, 30)
+	s.check(t, 5, 5)
+	s.check(t, 10, 10)
+	if s.Len() != 11 {
+		t.Errorf("Len should be 11, not %v.", s.Len())
+	}
+}
+
+func TestTxn(t *testing.T) {
+	s := NewIntMap()
+	for i := 0; i < 10; i++ {
+		s.Set(i, i)
+	}
+
+	txn := s.Begin()
+	txn.Set(3, 30)
+	txn.Set(20, 20)
+	if value, ok := txn.Delete(5); !ok || value != 5 {
+		t.Errorf("Delete should have returned 5, true, not %v, %v.", value, ok)
+	}
+	if _, ok := txn.Delete(50); ok {
+		t.Errorf("Delete of a missing key should return false.")
+	}
+	if value, ok := txn.Get(3); !ok || value != 30 {
+		t.Errorf("The transaction should see its own write (3: 30), not %v, %v.", value, ok)
+	}
+	if _, ok := txn.Get(5); ok {
+		t.Errorf("The transaction should see its own deletion of 5.")
+	}
+	if value, ok := txn.Get(7); !ok || value != 7 {
+		t.Errorf("The transaction should see the underlying list (7: 7), not %v, %v.", value, ok)
+	}
+
+	s.check(t, 3, 3)
+	s.check(t, 5, 5)
+	if _, ok := s.Get(20); ok {
+		t.Errorf("The writes of the transaction shouldn't be visible before Commit.")
+	}
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v.", err)
+	}
+	s.check(t, 3, 30)
+	s.check(t, 20, 20)
+	if _, ok := s.Get(5); ok {
+		t.Errorf("5 should have been deleted by Commit.")
+	}
+	if err := txn.Commit(); err != ErrTxnDone {
+		t.Errorf("A second Commit should return ErrTxnDone, not %v.", err)
+	}
+
+	txn = s.Begin()
+	txn.Set(3, 300)
+	txn.Delete(20)
+	txn.Rollback()
+	s.check(t, 3, 30)
+	s.check(t, 20, 20)
+	if err := txn.Commit(); err != ErrTxnDone {
+		t.Errorf("Commit after Rollback should return ErrTxnDone, not %v.", err)
+	}
+}
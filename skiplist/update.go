@@ -0,0 +1,106 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+// The methods in this file read and modify the element with a given
+// key using a single search. SkipList is not safe for concurrent use;
+// ConcurrentMap exposes them as atomic operations.
+
+// update finds key in s and calls f with its value and whether it is
+// present. If f returns keep, the value returned by f is stored for
+// key; otherwise, key is removed. It returns the resulting value and
+// whether key is present afterwards.
+func (s *SkipList) update(key interface{}, f func(old interface{}, exists bool) (new interface{}, keep bool)) (value interface{}, ok bool) {
+	if key == nil {
+		panic("goskiplist: nil keys are not supported")
+	}
+	if s.checker != nil {
+		s.checker.observe(key)
+	}
+	update := make([]*node, s.level()+1, s.effectiveMaxLevel()+1)
+	candidate := s.search(update, key)
+
+	if candidate != nil && s.keysEqual(candidate.key, key) && !s.hidden(candidate) {
+		value, ok = f(candidate.value, true)
+		switch {
+		case ok:
+			s.replace(candidate, value)
+		case s.ranges != nil:
+			value = nil
+			s.replace(candidate, tombstone{})
+		default:
+			value = nil
+			s.remove(update, candidate)
+		}
+	} else if candidate != nil && s.keysEqual(candidate.key, key) {
+		// candidate is a tombstone.
+		if value, ok = f(nil, false); ok {
+			s.replace(candidate, value)
+		} else {
+			value = nil
+		}
+	} else {
+		value, ok = f(nil, false)
+		if ok {
+			s.insert(update, key, value)
+		} else {
+			value = nil
+		}
+	}
+	s.setFinger(update)
+	return value, ok
+}
+
+// Update calls f with the value associated with key and whether key is
+// present in s. If f returns keep, new becomes the value associated
+// with key (which is inserted if necessary); otherwise, key is removed
+// from s. Update returns the value associated with key afterwards, and
+// whether key is present.
+//
+// f must not modify s.
+func (s *SkipList) Update(key interface{}, f func(old interface{}, exists bool) (new interface{}, keep bool)) (value interface{}, ok bool) {
+	return s.update(key, f)
+}
+
+// GetOrSet returns the value associated with key if it is present in
+// s. Otherwise, it sets it to value and returns value. The second
+// return value is true if key was present.
+func (s *SkipList) GetOrSet(key, value interface{}) (actual interface{}, loaded bool) {
+	actual, _ = s.update(key, func(old interface{}, exists bool) (interface{}, bool) {
+		if exists {
+			loaded = true
+			return old, true
+		}
+		return value, true
+	})
+	return actual, loaded
+}
+
+// CompareAndSwap sets the value associated with key to new if key is
+// present in s and its value is equal (==) to old. It returns true if
+// the value was swapped. The values must be comparable.
+func (s *SkipList) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	s.update(key, func(value interface{}, exists bool) (interface{}, bool) {
+		if exists && value == old {
+			swapped = true
+			return new, true
+		}
+		return value, exists
+	})
+	return swapped
+}
+
+// CompareAndDelete removes key from s if it is present and its value
+// is equal (==) to old. It returns true if key was removed. The values
+// must be comparable.
+func (s *SkipList) CompareAndDelete(key, old interface{}) (deleted bool) {
+	s.update(key, func(value interface{}, exists bool) (interface{}, bool) {
+		if exists && value == old {
+			deleted = true
+			return nil, false
+		}
+		return value, exists
+	})
+	return deleted
+}
@@ -0,0 +1,96 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	for _, s := range []*SkipList{NewIntMap(), NewDeterministicMap(intLessThan)} {
+		increment := func(old interface{}, exists bool) (interface{}, bool) {
+			if !exists {
+				return 1, true
+			}
+			return old.(int) + 1, true
+		}
+		for i := 0; i < 1000; i++ {
+			s.Update(rand.Intn(100), increment)
+		}
+		total := 0
+		for i := s.Iterator(); i.Next(); {
+			total += i.Value().(int)
+		}
+		if total != 1000 {
+			t.Errorf("The counters should add up to 1000, not %v.", total)
+		}
+
+		for i := 0; i < 100; i += 2 {
+			value, ok := s.Update(i, func(old interface{}, exists bool) (interface{}, bool) {
+				return nil, false
+			})
+			if ok || value != nil {
+				t.Errorf("Update should have removed %v, not returned %v, %v.", i, value, ok)
+			}
+		}
+		for i := 0; i < 100; i += 2 {
+			if _, ok := s.Get(i); ok {
+				t.Errorf("%v should have been removed.", i)
+			}
+		}
+		if err := s.Verify(); err != nil {
+			t.Fatalf("Verify failed: %v.", err)
+		}
+
+		if value, ok := s.Update(1000, func(old interface{}, exists bool) (interface{}, bool) {
+			return nil, false
+		}); ok || value != nil {
+			t.Errorf("Update of a missing key without keep should return nil, false, not %v, %v.", value, ok)
+		}
+		if _, ok := s.Get(1000); ok {
+			t.Errorf("Update without keep shouldn't insert the key.")
+		}
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	s := NewIntMap()
+	if actual, loaded := s.GetOrSet(1, 10); loaded || actual != 10 {
+		t.Errorf("GetOrSet should have returned 10, false, not %v, %v.", actual, loaded)
+	}
+	if actual, loaded := s.GetOrSet(1, 20); !loaded || actual != 10 {
+		t.Errorf("GetOrSet should have returned 10, true, not %v, %v.", actual, loaded)
+	}
+	s.check(t, 1, 10)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	s := NewIntMap()
+	s.Set(1, 10)
+	if s.CompareAndSwap(1, 20, 30) {
+		t.Errorf("CompareAndSwap with the wrong old value shouldn't succeed.")
+	}
+	if s.CompareAndSwap(2, nil, 30) {
+		t.Errorf("CompareAndSwap of a missing key shouldn't succeed.")
+	}
+	if _, ok := s.Get(2); ok {
+		t.Errorf("CompareAndSwap shouldn't insert missing keys.")
+	}
+	if !s.CompareAndSwap(1, 10, 30) {
+		t.Errorf("CompareAndSwap should have succeeded.")
+	}
+	s.check(t, 1, 30)
+
+	if s.CompareAndDelete(1, 10) {
+		t.Errorf("CompareAndDelete with the wrong old value shouldn't succeed.")
+	}
+	s.check(t, 1, 30)
+	if !s.CompareAndDelete(1, 30) {
+		t.Errorf("CompareAndDelete should have succeeded.")
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len should be 0, not %v.", s.Len())
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"fmt"
+)
+
+// A VerifyError describes a broken invariant found by Verify.
+type VerifyError struct {
+	// Level is the level at which the problem was found, or -1 if
+	// the problem is not specific to any level.
+	Level int
+	// Key is the key of the offending node. It is nil if the
+	// problem concerns the header or the list as a whole.
+	Key interface{}
+	// Problem is a human readable description of the problem.
+	Problem string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Level < 0 {
+		return fmt.Sprintf("goskiplist: key %v: %s", e.Key, e.Problem)
+	}
+	return fmt.Sprintf("goskiplist: level %d, key %v: %s", e.Level, e.Key, e.Problem)
+}
+
+// Verify checks the structural invariants of s and returns a
+// *VerifyError describing the first violation found, or nil if s is
+// consistent. It checks that the keys are strictly ascending at every
+// level, that every level is a subsequence of the level below, that
+// the backward links mirror the level 0 forward links, that the footer
+// is the last node, that the length matches the number of nodes, and
+// that no tower is higher than the effective MaxLevel. For
+// deterministic skip lists, it also checks the sizes of the gaps
+// between towers.
+//
+// Verify takes O(n log n) time, so it is meant for debugging and
+// tests.
+func (s *SkipList) Verify() error {
+	if len(s.header.forward) == 0 {
+		return &VerifyError{-1, nil, "the header has no forward links"}
+	}
+
+	// Level 0 holds all the nodes.
+	count, tombstones := 0, 0
+	heights := make([]int, len(s.header.forward))
+	var previous *node
+	for current := s.header.next(); current != nil; current = current.next() {
+		count++
+		if count > s.length {
+			return &VerifyError{0, current.key, fmt.Sprintf("there are more nodes than the length (%d)", s.length)}
+		}
+		if current.key == nil {
+			return &VerifyError{0, nil, "node with a nil key"}
+		}
+		if isTombstone(current.value) {
+			tombstones++
+		}
+		// Towers may be higher than MaxLevel if it was lowered
+		// after they were built, but never higher than the
+		// header, which bounds the effective MaxLevel.
+		height := len(current.forward) - 1
+		if height > s.level() {
+			return &VerifyError{height, current.key, fmt.Sprintf("tower is higher than the header (%d)", s.level())}
+		}
+		for i := 0; i <= height; i++ {
+			heights[i]++
+		}
+		if current.backward != previous {
+			return &VerifyError{0, current.key, "backward link doesn't point to the previous node"}
+		}
+		if previous != nil && !s.lessThan(previous.key, current.key) {
+			return &VerifyError{0, current.key, fmt.Sprintf("key is not greater than the previous key (%v)", previous.key)}
+		}
+		previous = current
+	}
+
+	if count != s.length {
+		return &VerifyError{-1, nil, fmt.Sprintf("length is %d, but there are %d nodes", s.length, count)}
+	}
+	if tombstones != s.tombstones {
+		return &VerifyError{-1, nil, fmt.Sprintf("%d tombstones are counted, but there are %d", s.tombstones, tombstones)}
+	}
+	if s.footer != previous {
+		return &VerifyError{-1, nil, "footer is not the last node"}
+	}
+
+	for i := 1; i <= s.level(); i++ {
+		count = 0
+		below := s.header
+		for current := s.header.forward[i]; current != nil; current = current.forward[i] {
+			count++
+			if len(current.forward) <= i {
+				return &VerifyError{i, current.key, fmt.Sprintf("node is linked at level %d, but its tower has height %d", i, len(current.forward))}
+			}
+			for below != nil && below != current {
+				below = below.forward[i-1]
+			}
+			if below == nil {
+				return &VerifyError{i, current.key, fmt.Sprintf("node is missing from level %d", i-1)}
+			}
+			if count > 1 && !s.lessThan(previous.key, current.key) {
+				return &VerifyError{i, current.key, fmt.Sprintf("key is not greater than the previous key (%v)", previous.key)}
+			}
+			previous = current
+		}
+		if count != heights[i] {
+			return &VerifyError{i, nil, fmt.Sprintf("%d nodes are linked, but %d towers reach this level", count, heights[i])}
+		}
+	}
+
+	if s.deterministic {
+		return s.verifyGaps()
+	}
+	return nil
+}
+
+// Verify checks the structural invariants of the underlying skip
+// list. See SkipList.Verify.
+func (s *Set) Verify() error {
+	return s.skiplist.Verify()
+}
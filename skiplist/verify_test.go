@@ -0,0 +1,98 @@
+// Copyright 2012 Google Inc. All rights reserved.
+// Author: Ric Szopa (Ryszard) <ryszard.szopa@gmail.com>
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	s := NewIntMap()
+	if err := s.Verify(); err != nil {
+		t.Errorf("An empty list should verify, got %v.", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.Set(rand.Intn(2000), i)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed after insertions: %v.", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.Delete(rand.Intn(2000))
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify failed after deletions: %v.", err)
+	}
+
+	set := NewIntSet()
+	for i := 0; i < 100; i++ {
+		set.Add(i)
+	}
+	if err := set.Verify(); err != nil {
+		t.Errorf("Verify failed for a set: %v.", err)
+	}
+}
+
+func verifyError(t *testing.T, s *SkipList, what string) *VerifyError {
+	err := s.Verify()
+	if err == nil {
+		t.Errorf("Verify didn't notice %s.", what)
+		return nil
+	}
+	return err.(*VerifyError)
+}
+
+func TestVerifyCorruption(t *testing.T) {
+	makeList := func() *SkipList {
+		s := NewIntMap()
+		for i := 0; i < 100; i++ {
+			s.Set(i, i)
+		}
+		return s
+	}
+
+	s := makeList()
+	s.length++
+	verifyError(t, s, "a wrong length")
+
+	s = makeList()
+	s.footer = s.header.next()
+	verifyError(t, s, "a wrong footer")
+
+	s = makeList()
+	s.header.next().next().backward = nil
+	if err := verifyError(t, s, "a broken backward link"); err != nil && err.Key != 1 {
+		t.Errorf("Verify reported the wrong key: %v.", err)
+	}
+
+	s = makeList()
+	n := s.header.next().next()
+	n.key = -1
+	if err := verifyError(t, s, "keys out of order"); err != nil && (err.Level != 0 || err.Key != -1) {
+		t.Errorf("Verify reported the wrong place: %v.", err)
+	}
+
+	s = makeList()
+	s.MaxLevel = 0
+	n = s.header.next()
+	for i := len(n.forward); i <= s.level()+1; i++ {
+		n.forward = append(n.forward, nil)
+	}
+	verifyError(t, s, "a tower higher than the header")
+
+	s = makeList()
+	for n = s.header.next(); len(n.forward) < 2; n = n.next() {
+	}
+	n.forward = n.forward[:1]
+	verifyError(t, s, "a tower which is too short")
+
+	s = makeList()
+	for n = s.header.next(); len(n.forward) < 2; n = n.next() {
+	}
+	n.forward = append(n.forward[:1], nil)
+	verifyError(t, s, "a level cut short")
+}